@@ -6,41 +6,65 @@ import (
 	"os"
 	"path"
 	"strconv"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
 )
 
 const (
-	sysPath          = "/sys/class/backlight"
+	// DefaultSysfs is the default mount point of sysfs.
+	DefaultSysfs     = "/sys"
 	maxBrightness    = "max_brightness"
 	actualBrightness = "actual_brightness"
 	brightness       = "brightness"
-	dimIncrement     = 5
+	blPower          = "bl_power"
+
+	// values of bl_power, see FB_BLANK_* in linux/fb.h.
+	blPowerOn  = 0
+	blPowerOff = 4
+
+	wOK = 0x2 // W_OK flag of access(2)
+
+	subsystemBacklight = "backlight"
+	subsystemLeds      = "leds"
 )
 
-// Backlight defines a backlight class from /sys/class/backlight.
+// Backlight defines a backlight class from /sys/class/backlight or a led
+// class from /sys/class/leds.
 type Backlight struct {
-	syspath string
-	Max     int
+	Name      string
+	Subsystem string
+	Curve     Curve            // brightness curve, defaults to linear
+	Writer    BrightnessWriter // writes brightness values, nil to write directly to sysfs
+	syspath   string
+	Max       int
+	last      atomic.Int64 // last value written by Set
 }
 
-// NewBacklight sets up a backlight struct.
-func NewBacklight(syspath string) (*Backlight, error) {
-	backlight := &Backlight{
-		syspath: path.Join(sysPath, syspath),
-	}
+// NewBacklight sets up a backlight struct for a device in
+// <sysfs>/class/backlight.
+func NewBacklight(sysfs, name string) (*Backlight, error) {
+	return newBacklight(sysfs, subsystemBacklight, name)
+}
 
-	// hack to ensure the /sys/class/backlight/<file> has been created by
-	// the kernel.
-	expBackoff := backoff.NewExponentialBackOff()
-	expBackoff.MaxInterval = 2 * time.Second
-	expBackoff.MaxElapsedTime = 60 * time.Second
+// NewLed sets up a backlight struct for a device in <sysfs>/class/leds e.g.
+// a keyboard backlight.
+func NewLed(sysfs, name string) (*Backlight, error) {
+	return newBacklight(sysfs, subsystemLeds, name)
+}
+
+func newBacklight(sysfs, subsystem, name string) (*Backlight, error) {
+	var backlight *Backlight
 
-	err := backoff.Retry(func() error {
-		_, err := backlight.ReadMax()
+	// hack to ensure the /sys/class/<subsystem>/<file> has been created by
+	// the kernel.
+	err := waitForDevice(func() error {
+		var err error
+		backlight, err = openBacklight(sysfs, subsystem, name)
 		return err
-	}, expBackoff)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -48,6 +72,41 @@ func NewBacklight(syspath string) (*Backlight, error) {
 	return backlight, nil
 }
 
+// openBacklight sets up a backlight struct for a device in
+// <sysfs>/class/<subsystem> without waiting for the device to be created.
+func openBacklight(sysfs, subsystem, name string) (*Backlight, error) {
+	backlight := &Backlight{
+		Name:      name,
+		Subsystem: subsystem,
+		Curve:     linearCurve{},
+		syspath:   path.Join(classPath(sysfs, subsystem), name),
+	}
+	backlight.last.Store(-1)
+
+	_, err := backlight.ReadMax()
+	if err != nil {
+		return nil, err
+	}
+
+	return backlight, nil
+}
+
+// classPath returns the path to the device class dir of subsystem.
+func classPath(sysfs, subsystem string) string {
+	return path.Join(sysfs, "class", subsystem)
+}
+
+// waitForDevice retries fn with exponential backoff for up to 60 seconds.
+// Used to wait for the kernel to create devices which may not be available
+// yet when lis is started during boot.
+func waitForDevice(fn func() error) error {
+	expBackoff := backoff.NewExponentialBackOff()
+	expBackoff.MaxInterval = 2 * time.Second
+	expBackoff.MaxElapsedTime = 60 * time.Second
+
+	return backoff.Retry(fn, expBackoff)
+}
+
 // reads the value of a 'brightness' file.
 func readInt(fpath string) (int, error) {
 	buf, err := ioutil.ReadFile(fpath)
@@ -82,9 +141,7 @@ func (b *Backlight) ReadMax() (int, error) {
 
 // Get actual brightness value.
 func (b *Backlight) Get() (int, error) {
-	fpath := path.Join(b.syspath, actualBrightness)
-
-	current, err := readInt(fpath)
+	current, err := readInt(b.ActualPath())
 	if err != nil {
 		return 0, err
 	}
@@ -97,68 +154,61 @@ func (b *Backlight) Set(value int) error {
 	if value < 0 || value > b.Max {
 		return fmt.Errorf("invalid brightness value '%d'", value)
 	}
-	fpath := path.Join(b.syspath, brightness)
-	val := strconv.Itoa(value)
 
-	fd, err := os.Create(fpath)
-	if err != nil {
-		return err
+	b.last.Store(int64(value))
+
+	if b.Writer != nil {
+		return b.Writer.SetBrightness(b.Subsystem, b.Name, value)
+	}
+
+	return writeInt(path.Join(b.syspath, brightness), value)
+}
+
+// SetPower powers the display of a backlight device on or off through
+// bl_power.
+func (b *Backlight) SetPower(on bool) error {
+	value := blPowerOff
+	if on {
+		value = blPowerOn
 	}
 
-	_, err = fd.WriteString(val)
+	return writeInt(path.Join(b.syspath, blPower), value)
+}
+
+// writes an integer value to a sysfs file.
+func writeInt(fpath string, value int) error {
+	fd, err := os.Create(fpath)
 	if err != nil {
 		return err
 	}
 
-	err = fd.Close()
+	_, err = fd.WriteString(strconv.Itoa(value))
 	if err != nil {
+		fd.Close()
 		return err
 	}
 
-	return nil
+	return fd.Close()
 }
 
-// Dim backlight from start to end.
-func (b *Backlight) Dim(start, end int, errChan chan error) {
-	var err error
-	delta := start - end
-	interval := delta / dimIncrement
-	current := start
-
-	for i := 0; i < dimIncrement; i++ {
-		current -= interval
-		if i == dimIncrement-1 {
-			current -= (delta % dimIncrement)
-		}
-		time.Sleep(50 * time.Millisecond)
-		err = b.Set(current)
-		if err != nil {
-			errChan <- err
-		}
-	}
+// LastSet returns the last value written by Set, or -1 if Set was never
+// called.
+func (b *Backlight) LastSet() int {
+	return int(b.last.Load())
 }
 
-// UnDim backlight from start to end.
-func (b *Backlight) UnDim(start, end int, errChan chan error) {
-	var err error
-	delta := end - start
-	interval := delta / dimIncrement
-	current := start
-
-	for i := 0; i < dimIncrement; i++ {
-		current += interval
-		if i == dimIncrement-1 {
-			current += (delta % dimIncrement)
-		}
-		time.Sleep(50 * time.Millisecond)
-		err = b.Set(current)
-		if err != nil {
-			errChan <- err
-		}
-	}
+// Writable returns true if the brightness file of the backlight can be
+// written by the current user.
+func (b *Backlight) Writable() bool {
+	return syscall.Access(path.Join(b.syspath, brightness), wOK) == nil
 }
 
-// ActualPath gets the sys-path to actual_brightness.
+// ActualPath gets the sys-path to actual_brightness. Led devices doesn't
+// have an actual_brightness file, for those the path to brightness is
+// returned.
 func (b *Backlight) ActualPath() string {
+	if b.Subsystem == subsystemLeds {
+		return path.Join(b.syspath, brightness)
+	}
 	return path.Join(b.syspath, actualBrightness)
 }
@@ -0,0 +1,119 @@
+package lis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikkeloscar/lis/internal/sysfstest"
+)
+
+func TestFade(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 937, 937)
+	sysfs.AddLed("tpacpi::kbd_backlight", 2, 2)
+
+	fade := DefaultFade
+	fade.Duration = 20 * time.Millisecond
+	fade.Rate = 1000
+
+	for _, tc := range []struct {
+		subsystem string
+		new       func(string, string) (*Backlight, error)
+		name      string
+		max       int
+	}{
+		{subsystemBacklight, NewBacklight, "intel_backlight", 937},
+		{subsystemLeds, NewLed, "tpacpi::kbd_backlight", 2},
+	} {
+		b, err := tc.new(sysfs.Root, tc.name)
+		if err != nil {
+			t.Fatalf("failed to setup backlight: %s", err)
+		}
+
+		if b.Max != tc.max {
+			t.Errorf("expected max %d, got %d", tc.max, b.Max)
+		}
+
+		err = b.Fade(context.Background(), fade, tc.max, 0)
+		if err != nil {
+			t.Errorf("should not cause error: %s", err)
+		}
+
+		if v := sysfs.Brightness(tc.subsystem, tc.name); v != 0 {
+			t.Errorf("expected %s to be dimmed to 0, was %d", tc.name, v)
+		}
+
+		err = b.Fade(context.Background(), fade, 0, tc.max)
+		if err != nil {
+			t.Errorf("should not cause error: %s", err)
+		}
+
+		if v := sysfs.Brightness(tc.subsystem, tc.name); v != tc.max {
+			t.Errorf("expected %s to be undimmed to %d, was %d", tc.name, tc.max, v)
+		}
+
+		v, err := b.Get()
+		if err != nil {
+			t.Errorf("should not cause error: %s", err)
+		}
+
+		if v != tc.max {
+			t.Errorf("expected actual brightness %d, got %d", tc.max, v)
+		}
+	}
+}
+
+func TestFadeFrames(t *testing.T) {
+	fade := Fade{Duration: time.Second, Rate: 60}
+
+	for _, tc := range []struct {
+		delta    int
+		frames   int
+		interval time.Duration
+	}{
+		{937, 60, time.Second / 60},
+		{-937, 60, time.Second / 60},
+		{2, 2, 500 * time.Millisecond},
+		{0, 1, time.Second},
+	} {
+		frames, interval := fade.frames(tc.delta)
+		if frames != tc.frames || interval != tc.interval {
+			t.Errorf("expected %d frames every %s for delta %d, got %d every %s",
+				tc.frames, tc.interval, tc.delta, frames, interval)
+		}
+	}
+}
+
+type fakeWriter map[string]int
+
+func (w fakeWriter) SetBrightness(subsystem, name string, value int) error {
+	w[subsystem+"/"+name] = value
+	return nil
+}
+
+func TestSetWriter(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 937, 937)
+
+	b, err := NewBacklight(sysfs.Root, "intel_backlight")
+	if err != nil {
+		t.Fatalf("failed to setup backlight: %s", err)
+	}
+
+	writer := fakeWriter{}
+	b.Writer = writer
+
+	err = b.Set(100)
+	if err != nil {
+		t.Errorf("should not cause error: %s", err)
+	}
+
+	if writer["backlight/intel_backlight"] != 100 {
+		t.Errorf("expected brightness to be written through writer, got %v", writer)
+	}
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 937 {
+		t.Errorf("expected sysfs to be untouched, got %d", v)
+	}
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/mikkeloscar/lis"
 )
@@ -13,11 +20,26 @@ func usage(exit int) {
 Control lis daemon.
 
   COMMANDS:
-    set <+|-value%>    set/increase/decrease brightness level
-    status	   get current brightness level
-    dmps <on|off>  set dpms on/off
+    set <+|-value%> [device]  set/increase/decrease brightness level
+    status [device]           get current brightness level
+    dpms <on|off>             set dpms on/off
+    watch                     print brightness changes as they happen
+    kbd set <+|-value%>       set/increase/decrease keyboard backlight level
+    kbd status                get current keyboard backlight level
+    inhibit [--for <time>] [reason] [-- command ...]
+                              inhibit idle until interrupted, for <time>
+                              (e.g. 1h) or while running command
+    uninhibit <id>            release an inhibitor added with --for
+    devices                   list input devices and how they are classified
+
+  DEVICES:
+    panel                     all display backlights
+    panel=<name>              the display backlight <name>
+    kbd                       all keyboard backlights
+    kbd=<name>                the keyboard backlight <name>
 
   OPTIONS:
+    -s <socket>    path to the lis IPC socket
     -h, --help     display this help mesage
 `
 
@@ -25,9 +47,23 @@ Control lis daemon.
 	os.Exit(exit)
 }
 
+// arg returns the i'th command line argument or an empty string if not
+// defined.
+func arg(i int) string {
+	if len(os.Args) > i {
+		return os.Args[i]
+	}
+	return ""
+}
+
 func main() {
+	client := &lis.IPCClient{Socket: os.Getenv("LIS_SOCKET")}
+	if len(os.Args) > 2 && os.Args[1] == "-s" {
+		client.Socket = os.Args[2]
+		os.Args = append(os.Args[:1], os.Args[3:]...)
+	}
+
 	if len(os.Args) > 1 {
-		client := &lis.IPCClient{}
 		var err error
 		switch os.Args[1] {
 		case "set":
@@ -35,19 +71,63 @@ func main() {
 				// invalid command
 				usage(1)
 			}
-			err = client.Set(os.Args[2])
+			err = client.Set(os.Args[2], arg(3))
 		case "status":
 			var resp string
-			resp, err = client.Status()
+			resp, err = client.Status(arg(2))
 			if err == nil {
 				fmt.Println(resp)
 			}
+		case "watch":
+			err = client.Subscribe(func(event string) {
+				fmt.Println(event)
+			})
+		case "kbd":
+			if len(os.Args) < 3 {
+				// invalid command
+				usage(1)
+			}
+			switch os.Args[2] {
+			case "set":
+				if len(os.Args) < 4 {
+					// invalid command
+					usage(1)
+				}
+				err = client.Set(os.Args[3], "kbd")
+			case "status":
+				var resp string
+				resp, err = client.Status("kbd")
+				if err == nil {
+					fmt.Println(resp)
+				}
+			default:
+				// invalid command
+				usage(1)
+			}
 		case "dpms":
 			if len(os.Args) < 3 {
 				// invalid command
 				usage(1)
 			}
 			err = client.DPMS(os.Args[2])
+		case "inhibit":
+			err = inhibit(client, os.Args[2:])
+		case "uninhibit":
+			if len(os.Args) < 3 {
+				// invalid command
+				usage(1)
+			}
+			var id uint64
+			id, err = strconv.ParseUint(os.Args[2], 10, 32)
+			if err == nil {
+				err = client.UnInhibit(uint32(id))
+			}
+		case "devices":
+			var devices []string
+			devices, err = client.Devices()
+			for _, d := range devices {
+				fmt.Println(d)
+			}
 		case "-h", "--help":
 			usage(0)
 		default:
@@ -64,3 +144,75 @@ func main() {
 	// invalid command
 	usage(1)
 }
+
+// inhibit inhibits idle until interrupted, for a duration or while running
+// a command. The exit code of the command is passed on.
+func inhibit(client *lis.IPCClient, args []string) error {
+	var duration time.Duration
+	if len(args) > 1 && args[0] == "--for" {
+		var err error
+		duration, err = time.ParseDuration(args[1])
+		if err != nil {
+			return err
+		}
+		args = args[2:]
+	}
+
+	var command []string
+	for i, a := range args {
+		if a == "--" {
+			command = args[i+1:]
+			args = args[:i]
+			break
+		}
+	}
+
+	if duration > 0 && len(command) > 0 {
+		return errors.New("--for can't be combined with a command")
+	}
+
+	reason := strings.Join(args, " ")
+	if reason == "" {
+		reason = "lisc"
+		if len(command) > 0 {
+			reason = strings.Join(command, " ")
+		}
+	}
+
+	id, err := client.Inhibit(reason, duration)
+	if err != nil {
+		return err
+	}
+
+	if duration > 0 {
+		fmt.Println(id)
+		return nil
+	}
+	// the inhibitor is released when the connection is closed.
+	defer client.Close()
+
+	if len(command) == 0 {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		<-sigs
+		return nil
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// let the command handle interrupts, the inhibitor is released once
+	// it exits.
+	signal.Ignore(os.Interrupt)
+
+	err = cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		client.Close()
+		os.Exit(exit.ExitCode())
+	}
+
+	return err
+}
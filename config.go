@@ -2,15 +2,248 @@ package lis
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
 
+const (
+	// WriterAuto writes brightness values directly to sysfs if possible and
+	// falls back to logind.
+	WriterAuto = "auto"
+	// WriterSysfs writes brightness values directly to sysfs.
+	WriterSysfs = "sysfs"
+	// WriterLogind writes brightness values through logind.
+	WriterLogind = "logind"
+)
+
+// default idle time in milliseconds.
+const defaultIdleTime = 600000
+
 // Config defines the lis config struct.
 type Config struct {
-	StateFile string `toml:"statefile"`
-	Backlight string `toml:"backlight"`
-	IdleTime  uint   `toml:"idle"`
+	StateFile  string           `toml:"statefile"`
+	Backlight  StringList       `toml:"backlight"`
+	Keyboard   string           `toml:"keyboard"`
+	Curve      string           `toml:"curve"`
+	Gamma      float64          `toml:"gamma"`
+	IdleTime   uint             `toml:"idle"`
+	IdleSource string           `toml:"idlesource"`
+	Writer     string           `toml:"writer"`
+	Fade       FadeConfig       `toml:"fade"`
+	Brightness BrightnessConfig `toml:"brightness"`
+	Stages     []IdleStage      `toml:"stage"`
+	MPRIS      MPRISConfig      `toml:"mpris"`
+	Fullscreen FullscreenConfig `toml:"fullscreen"`
+	InputRules []InputRule      `toml:"input"`
+	Activity   ActivityConfig   `toml:"activity"`
+	Sysfs      string           `toml:"sysfs"`
+	InputDir   string           `toml:"inputdir"`
+	DRMDir     string           `toml:"drmdir"`
+	Socket     string           `toml:"socket"`
+}
+
+// FadeConfig defines the fade animation used when dimming/undimming.
+type FadeConfig struct {
+	Duration *uint  `toml:"duration"` // duration in milliseconds
+	Rate     uint   `toml:"rate"`     // max frames per second
+	Easing   string `toml:"easing"`
+}
+
+// Fade returns the Fade described by the config.
+func (c FadeConfig) Fade() (Fade, error) {
+	fade := DefaultFade
+
+	if c.Duration != nil {
+		fade.Duration = time.Duration(*c.Duration) * time.Millisecond
+	}
+
+	if c.Rate > 0 {
+		fade.Rate = int(c.Rate)
+	}
+
+	if c.Easing != "" {
+		easing, err := NewEasing(c.Easing)
+		if err != nil {
+			return Fade{}, err
+		}
+		fade.Easing = easing
+	}
+
+	return fade, nil
+}
+
+// BrightnessConfig defines the brightness limits of the display backlights.
+type BrightnessConfig struct {
+	Min *Level `toml:"min"` // lowest level which can be set
+	Max *Level `toml:"max"` // highest level which can be set
+	Dim *Level `toml:"dim"` // level to dim to when idle, defaults to Min
+}
+
+// MPRISConfig defines the media players inhibiting idle while playing.
+type MPRISConfig struct {
+	Enable bool       `toml:"enable"`
+	Allow  StringList `toml:"allow"` // players inhibiting idle, all if empty
+	Deny   StringList `toml:"deny"`  // players never inhibiting idle
+}
+
+// FullscreenConfig defines the X11 windows inhibiting idle while
+// fullscreen.
+type FullscreenConfig struct {
+	Enable bool       `toml:"enable"`
+	Allow  StringList `toml:"allow"` // WM_CLASS names inhibiting idle, all if empty
+	Deny   StringList `toml:"deny"`  // WM_CLASS names never inhibiting idle
+}
+
+// InputRule defines how the events of matching input devices are treated.
+// All given fields must match, rules are tried in order.
+type InputRule struct {
+	Name    string  `toml:"name"` // device name, may contain * and ? wildcards
+	Vendor  *uint16 `toml:"vendor"`
+	Product *uint16 `toml:"product"`
+	Phys    string  `toml:"phys"`  // physical path, may contain * and ? wildcards
+	Class   string  `toml:"class"` // see inputClasses
+	Action  string  `toml:"action"`
+}
+
+// ActivityConfig defines which input events count as user activity.
+type ActivityConfig struct {
+	Motion   uint `toml:"motion"`   // minimum motion within window
+	Window   uint `toml:"window"`   // window in milliseconds
+	KeyPress bool `toml:"keypress"` // ignore key releases and autorepeat
+	Debounce uint `toml:"debounce"` // milliseconds motion must persist for
+}
+
+// Filter returns the ActivityFilter described by the config.
+func (c ActivityConfig) Filter() ActivityFilter {
+	filter := ActivityFilter{
+		Motion:   int(c.Motion),
+		Window:   defaultMotionWindow,
+		KeyPress: c.KeyPress,
+		Debounce: time.Duration(c.Debounce) * time.Millisecond,
+	}
+
+	if c.Window > 0 {
+		filter.Window = time.Duration(c.Window) * time.Millisecond
+	}
+
+	return filter
+}
+
+// IdleStage defines an action taken when the user has been idle for some
+// time. Stages are entered in order and left when the user becomes active.
+type IdleStage struct {
+	Timeout uint       `toml:"timeout"` // idle time in milliseconds before the stage is entered
+	Action  string     `toml:"action"`
+	Level   *Level     `toml:"level"`   // dim: level to dim the display backlights to
+	Command StringList `toml:"command"` // lock: locker to run instead of locking the session
+}
+
+// validateStages checks that stages have known actions and strictly
+// increasing timeouts.
+func validateStages(stages []IdleStage) error {
+	var prev uint
+	for i, stage := range stages {
+		switch stage.Action {
+		case StageDim, StageScreenOff, StageLock, StageSuspend:
+		default:
+			return fmt.Errorf("stage %d: invalid action '%s', must be one of: %s", i+1, stage.Action, strings.Join(stageActions, ", "))
+		}
+
+		if stage.Timeout == 0 {
+			return fmt.Errorf("stage %d: missing timeout", i+1)
+		}
+
+		if stage.Timeout <= prev {
+			return fmt.Errorf("stage %d: timeout must be greater than the timeout of the previous stage", i+1)
+		}
+		prev = stage.Timeout
+
+		if stage.Level != nil && stage.Action != StageDim {
+			return fmt.Errorf("stage %d: level is only supported by the %s action", i+1, StageDim)
+		}
+
+		if len(stage.Command) > 0 && stage.Action != StageLock {
+			return fmt.Errorf("stage %d: command is only supported by the %s action", i+1, StageLock)
+		}
+	}
+
+	return nil
+}
+
+// Level is a brightness level given either as a raw value, e.g. 10, or as a
+// percent value, e.g. "10%".
+type Level struct {
+	Value   float64
+	Percent bool
+}
+
+// UnmarshalTOML implements the toml.Unmarshaler interface.
+func (l *Level) UnmarshalTOML(data interface{}) error {
+	switch v := data.(type) {
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("invalid brightness level: %d", v)
+		}
+		*l = Level{Value: float64(v)}
+	case string:
+		if !strings.HasSuffix(v, "%") {
+			return fmt.Errorf("invalid brightness level: %s", v)
+		}
+
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err != nil || pct < 0 || pct > 100 {
+			return fmt.Errorf("invalid brightness level: %s", v)
+		}
+		*l = Level{Value: pct / 100, Percent: true}
+	default:
+		return fmt.Errorf("expected raw value or percent string, got %T", data)
+	}
+
+	return nil
+}
+
+// Raw returns the raw brightness value of the level for a device with the
+// brightness curve and max value.
+func (l Level) Raw(curve Curve, max int) int {
+	if l.Percent {
+		return curve.ToRaw(clampPct(l.Value), max)
+	}
+
+	if int(l.Value) > max {
+		return max
+	}
+
+	return int(l.Value)
+}
+
+// StringList is a list of strings which can be specified as either a single
+// string or a list of strings in the config.
+type StringList []string
+
+// UnmarshalTOML implements the toml.Unmarshaler interface.
+func (s *StringList) UnmarshalTOML(data interface{}) error {
+	switch v := data.(type) {
+	case string:
+		*s = StringList{v}
+	case []interface{}:
+		list := make(StringList, 0, len(v))
+		for _, e := range v {
+			str, ok := e.(string)
+			if !ok {
+				return fmt.Errorf("expected string, got %T", e)
+			}
+			list = append(list, str)
+		}
+		*s = list
+	default:
+		return fmt.Errorf("expected string or list of strings, got %T", data)
+	}
+
+	return nil
 }
 
 // ReadConfig reads the config from filePath.
@@ -21,10 +254,80 @@ func ReadConfig(filePath string) (*Config, error) {
 		return nil, err
 	}
 
-	switch conf.Backlight {
-	case "intel", "amdgpu":
+	if len(conf.Backlight) == 0 {
+		conf.Backlight = StringList{BacklightAuto}
+	}
+
+	if conf.Keyboard == "" {
+		conf.Keyboard = BacklightAuto
+	}
+
+	if conf.IdleTime == 0 {
+		conf.IdleTime = defaultIdleTime
+	}
+
+	if conf.IdleSource == "" {
+		conf.IdleSource = IdleSourceAuto
+	}
+
+	if _, ok := idleSources[conf.IdleSource]; !ok && conf.IdleSource != IdleSourceAuto {
+		return nil, fmt.Errorf("idle source '%s' not supported, must be one of: %s", conf.IdleSource, strings.Join(append([]string{IdleSourceAuto}, IdleSources()...), ", "))
+	}
+
+	if conf.Curve == "" {
+		conf.Curve = CurveLinear
+	}
+
+	_, err = NewCurve(conf.Curve, conf.Gamma)
+	if err != nil {
+		return nil, err
+	}
+
+	switch conf.Writer {
+	case "":
+		conf.Writer = WriterAuto
+	case WriterAuto, WriterSysfs, WriterLogind:
 	default:
-		return nil, fmt.Errorf("invalid backlight type: %s", conf.Backlight)
+		return nil, fmt.Errorf("invalid writer: %s", conf.Writer)
+	}
+
+	conf.StateFile = os.ExpandEnv(conf.StateFile)
+	conf.Socket = os.ExpandEnv(conf.Socket)
+
+	min, max := conf.Brightness.Min, conf.Brightness.Max
+	if min != nil && max != nil && min.Percent == max.Percent && min.Value > max.Value {
+		return nil, fmt.Errorf("brightness min is greater than max")
+	}
+
+	_, err = conf.Fade.Fade()
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateStages(conf.Stages)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateInputRules(conf.InputRules)
+	if err != nil {
+		return nil, err
+	}
+
+	if conf.Sysfs == "" {
+		conf.Sysfs = DefaultSysfs
+	}
+
+	if conf.InputDir == "" {
+		conf.InputDir = DefaultInputDir
+	}
+
+	if conf.DRMDir == "" {
+		conf.DRMDir = DefaultDRMDir
+	}
+
+	if conf.Socket == "" {
+		conf.Socket = DefaultSocket
 	}
 
 	return &conf, nil
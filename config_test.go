@@ -0,0 +1,182 @@
+package lis
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestReadConfig(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "lis.conf")
+	err := ioutil.WriteFile(fpath, []byte(`
+statefile = "/var/lib/lis/brightness"
+backlight = ["intel_backlight", "acpi_video0"]
+idle = 30000
+
+[brightness]
+min = 10
+max = "90%"
+dim = "5%"
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	config, err := ReadConfig(fpath)
+	if err != nil {
+		t.Fatalf("should not cause error: %s", err)
+	}
+
+	if len(config.Backlight) != 2 || config.Backlight[1] != "acpi_video0" {
+		t.Errorf("unexpected backlight: %v", config.Backlight)
+	}
+
+	if config.Keyboard != BacklightAuto {
+		t.Errorf("expected keyboard %s, got %s", BacklightAuto, config.Keyboard)
+	}
+
+	for _, tc := range []struct {
+		level    *Level
+		expected Level
+	}{
+		{config.Brightness.Min, Level{Value: 10}},
+		{config.Brightness.Max, Level{Value: 0.9, Percent: true}},
+		{config.Brightness.Dim, Level{Value: 0.05, Percent: true}},
+	} {
+		if tc.level == nil || *tc.level != tc.expected {
+			t.Errorf("expected level %v, got %v", tc.expected, tc.level)
+		}
+	}
+
+	err = ioutil.WriteFile(fpath, []byte(`
+[brightness]
+min = "10"
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	_, err = ReadConfig(fpath)
+	if err == nil {
+		t.Errorf("expected error for invalid level")
+	}
+}
+
+func TestReadConfigStages(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "lis.conf")
+	err := ioutil.WriteFile(fpath, []byte(`
+[[stage]]
+timeout = 30000
+action = "dim"
+level = "20%"
+
+[[stage]]
+timeout = 60000
+action = "screenoff"
+
+[[stage]]
+timeout = 120000
+action = "lock"
+command = ["swaylock", "-f"]
+
+[[stage]]
+timeout = 600000
+action = "suspend"
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	config, err := ReadConfig(fpath)
+	if err != nil {
+		t.Fatalf("should not cause error: %s", err)
+	}
+
+	if len(config.Stages) != 4 {
+		t.Fatalf("expected 4 stages, got %d", len(config.Stages))
+	}
+
+	if s := config.Stages[0]; s.Level == nil || *s.Level != (Level{Value: 0.2, Percent: true}) {
+		t.Errorf("unexpected dim level: %v", s.Level)
+	}
+
+	if s := config.Stages[2]; len(s.Command) != 2 || s.Command[0] != "swaylock" {
+		t.Errorf("unexpected lock command: %v", s.Command)
+	}
+
+	for _, stages := range []string{
+		"[[stage]]\ntimeout = 1000\naction = \"hibernate\"\n",
+		"[[stage]]\naction = \"dim\"\n",
+		"[[stage]]\ntimeout = 2000\naction = \"dim\"\n[[stage]]\ntimeout = 1000\naction = \"suspend\"\n",
+		"[[stage]]\ntimeout = 1000\naction = \"suspend\"\nlevel = \"10%\"\n",
+	} {
+		err = ioutil.WriteFile(fpath, []byte(stages), 0644)
+		if err != nil {
+			t.Fatalf("failed to write config: %s", err)
+		}
+
+		_, err = ReadConfig(fpath)
+		if err == nil {
+			t.Errorf("expected error for stages: %q", stages)
+		}
+	}
+}
+
+func TestReadConfigInputRules(t *testing.T) {
+	fpath := path.Join(t.TempDir(), "lis.conf")
+	err := ioutil.WriteFile(fpath, []byte(`
+[[input]]
+vendor = 0x1050
+action = "ignore"
+
+[[input]]
+name = "Lid Switch"
+class = "other"
+action = "wake-only"
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	config, err := ReadConfig(fpath)
+	if err != nil {
+		t.Fatalf("should not cause error: %s", err)
+	}
+
+	if len(config.InputRules) != 2 {
+		t.Fatalf("expected 2 input rules, got %d", len(config.InputRules))
+	}
+
+	if r := config.InputRules[0]; r.Vendor == nil || *r.Vendor != 0x1050 || r.Action != InputIgnore {
+		t.Errorf("unexpected input rule: %+v", r)
+	}
+
+	err = ioutil.WriteFile(fpath, []byte("[[input]]\nname = \"Lid Switch\"\naction = \"sleep\"\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	_, err = ReadConfig(fpath)
+	if err == nil {
+		t.Errorf("expected error for invalid input action")
+	}
+}
+
+func TestActivityConfigFilter(t *testing.T) {
+	filter := ActivityConfig{}.Filter()
+	if filter != (ActivityFilter{Window: defaultMotionWindow}) {
+		t.Errorf("unexpected default filter: %+v", filter)
+	}
+
+	filter = ActivityConfig{Motion: 20, Window: 500, KeyPress: true, Debounce: 100}.Filter()
+	expected := ActivityFilter{
+		Motion:   20,
+		Window:   500 * time.Millisecond,
+		KeyPress: true,
+		Debounce: 100 * time.Millisecond,
+	}
+	if filter != expected {
+		t.Errorf("expected filter %+v, got %+v", expected, filter)
+	}
+}
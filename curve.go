@@ -0,0 +1,79 @@
+package lis
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// CurveLinear maps brightness linearly to raw values.
+	CurveLinear = "linear"
+	// CurveLogarithmic maps brightness such that equal steps are perceived
+	// as equal changes by the eye.
+	CurveLogarithmic = "logarithmic"
+	// CurveExponential is an alias for CurveLogarithmic. The raw value grows
+	// exponentially with the perceived brightness.
+	CurveExponential = "exponential"
+	// CurveGamma maps brightness with a user supplied gamma.
+	CurveGamma = "gamma"
+)
+
+// Curve maps perceived brightness, a value between 0 and 1, to raw
+// brightness values between 0 and max and back.
+type Curve interface {
+	ToRaw(value float64, max int) int
+	FromRaw(raw, max int) float64
+}
+
+// NewCurve returns the curve identified by name. gamma is only used by the
+// gamma curve.
+func NewCurve(name string, gamma float64) (Curve, error) {
+	switch name {
+	case "", CurveLinear:
+		return linearCurve{}, nil
+	case CurveLogarithmic, CurveExponential:
+		return logCurve{}, nil
+	case CurveGamma:
+		if gamma <= 0 {
+			return nil, fmt.Errorf("invalid gamma value: %f", gamma)
+		}
+		return gammaCurve(gamma), nil
+	default:
+		return nil, fmt.Errorf("invalid curve: %s", name)
+	}
+}
+
+// linearCurve maps perceived brightness linearly. (max * value).
+type linearCurve struct{}
+
+func (linearCurve) ToRaw(value float64, max int) int {
+	return int(math.Round(float64(max) * value))
+}
+
+func (linearCurve) FromRaw(raw, max int) float64 {
+	return float64(raw) / float64(max)
+}
+
+// logCurve follows the logarithmic response of the eye, such that the
+// perceived brightness is proportional to log(raw + 1).
+// ((max + 1)^value - 1).
+type logCurve struct{}
+
+func (logCurve) ToRaw(value float64, max int) int {
+	return int(math.Round(math.Pow(float64(max)+1, value) - 1))
+}
+
+func (logCurve) FromRaw(raw, max int) float64 {
+	return math.Log(float64(raw)+1) / math.Log(float64(max)+1)
+}
+
+// gammaCurve maps perceived brightness with a gamma. (max * value^gamma).
+type gammaCurve float64
+
+func (g gammaCurve) ToRaw(value float64, max int) int {
+	return int(math.Round(float64(max) * math.Pow(value, float64(g))))
+}
+
+func (g gammaCurve) FromRaw(raw, max int) float64 {
+	return math.Pow(float64(raw)/float64(max), 1/float64(g))
+}
@@ -0,0 +1,51 @@
+package lis
+
+import (
+	"testing"
+)
+
+func TestCurves(t *testing.T) {
+	for _, name := range []string{CurveLinear, CurveLogarithmic, CurveGamma} {
+		curve, err := NewCurve(name, 2.2)
+		if err != nil {
+			t.Fatalf("failed to setup curve %s: %s", name, err)
+		}
+
+		for _, max := range []int{2, 15, 255, 937, 120000} {
+			if v := curve.ToRaw(0, max); v != 0 {
+				t.Errorf("%s: expected 0%% to be 0, got %d", name, v)
+			}
+
+			if v := curve.ToRaw(1, max); v != max {
+				t.Errorf("%s: expected 100%% to be %d, got %d", name, max, v)
+			}
+
+			prev := 0.0
+			for raw := 0; raw <= max; raw += 1 + max/100 {
+				value := curve.FromRaw(raw, max)
+				if v := curve.ToRaw(value, max); v != raw {
+					t.Errorf("%s: %d doesn't map back to itself, got %d", name, raw, v)
+				}
+
+				if value < prev {
+					t.Errorf("%s: curve is not monotonic at %d", name, raw)
+				}
+				prev = value
+			}
+		}
+	}
+
+	// the logarithmic curve should use small raw steps at the bottom of the
+	// range and large steps at the top.
+	curve := logCurve{}
+	low := curve.ToRaw(0.1, 937) - curve.ToRaw(0.05, 937)
+	high := curve.ToRaw(1, 937) - curve.ToRaw(0.95, 937)
+	if low >= high {
+		t.Errorf("expected low steps (%d) to be smaller than high steps (%d)", low, high)
+	}
+
+	_, err := NewCurve("cubic", 0)
+	if err == nil {
+		t.Errorf("expected error for invalid curve")
+	}
+}
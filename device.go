@@ -0,0 +1,179 @@
+package lis
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+const (
+	// classPanel is the device class of display backlights.
+	classPanel = "panel"
+	// classKbd is the device class of keyboard backlights.
+	classKbd = "kbd"
+)
+
+// device is a backlight device managed by lis.
+type device struct {
+	*Backlight
+	class    string // device class used for IPC addressing
+	selector string // config value used to resolve the device
+	bound    bool   // false if the device has been removed
+	current  uint16 // current brightness value chosen by the user
+	min      Level  // lowest level which can be set
+	max      Level  // highest level which can be set
+	dimTo    Level  // level to dim to when idle
+	fade     Fade   // fade used when dimming/undimming
+	fader    fader
+}
+
+// subsystem returns the sysfs subsystem of devices of the class.
+func (d *device) subsystem() string {
+	if d.class == classKbd {
+		return subsystemLeds
+	}
+	return subsystemBacklight
+}
+
+// limits of devices without configured limits.
+var (
+	minLevel = Level{Value: 0}
+	maxLevel = Level{Value: 1, Percent: true}
+)
+
+// clamp clamps a raw value to the limits of the device.
+func (d *device) clamp(val int) int {
+	min := d.min.Raw(d.Curve, d.Max)
+	max := d.max.Raw(d.Curve, d.Max)
+
+	if val > max {
+		val = max
+	}
+
+	if val < min {
+		val = min
+	}
+
+	return val
+}
+
+// dimLevel returns the raw value to dim to. A level given by an idle stage
+// applies to display backlights, other devices use their own dim level.
+// The device is never brightened by dimming.
+func (d *device) dimLevel(level *Level) int {
+	to := d.dimTo
+	if level != nil && d.class == classPanel {
+		to = *level
+	}
+
+	val := to.Raw(d.Curve, d.Max)
+	if val > int(d.current) {
+		return int(d.current)
+	}
+	return val
+}
+
+// getPercent gets the current backlight value as a percent value mapped
+// through the brightness curve of the device.
+func (d *device) getPercent() (float64, error) {
+	val, err := d.Get()
+	if err != nil {
+		return 0, err
+	}
+
+	return d.Curve.FromRaw(val, d.Max), nil
+}
+
+// setPercent sets the current value from a percent value mapped through the
+// brightness curve of the device.
+func (d *device) setPercent(value float64) error {
+	if value > 1 || value < 0 {
+		return fmt.Errorf("invalid percent value: %f", value)
+	}
+
+	return d.setRaw(d.clamp(d.Curve.ToRaw(value, d.Max)))
+}
+
+// stepPercent increases (or decreases if negative) the current value by a
+// percent value. The raw value is changed by at least one unit, such that a
+// step is never lost to rounding in the flat parts of the brightness curve.
+func (d *device) stepPercent(delta float64) error {
+	d.fader.stop()
+
+	raw, err := d.Get()
+	if err != nil {
+		return err
+	}
+
+	current := d.Curve.FromRaw(raw, d.Max)
+	val := d.Curve.ToRaw(clampPct(current+delta), d.Max)
+	switch {
+	case delta > 0 && val <= raw && raw < d.Max:
+		val = raw + 1
+	case delta < 0 && val >= raw && raw > 0:
+		val = raw - 1
+	}
+
+	return d.setRaw(d.clamp(val))
+}
+
+// setRaw sets the current value to a raw brightness value. A running fade
+// is cancelled.
+func (d *device) setRaw(val int) error {
+	d.fader.stop()
+	d.current = uint16(val)
+	return d.Set(val)
+}
+
+// refresh reads the current brightness value from the device.
+func (d *device) refresh() error {
+	v, err := d.Get()
+	if err != nil {
+		return err
+	}
+
+	d.current = uint16(v)
+
+	return nil
+}
+
+// dim device.
+func (d *device) dim(to *Level, errors chan error) {
+	level := d.dimLevel(to)
+	slog.Info(fmt.Sprintf("Dimming %s from brightness level %d to %d", d.Name, d.current, level))
+	d.fadeTo(level, errors)
+}
+
+// undim device.
+func (d *device) unDim(errors chan error) {
+	slog.Info(fmt.Sprintf("Undimming %s to brightness level %d", d.Name, d.current))
+	d.fadeTo(int(d.current), errors)
+}
+
+// fadeTo fades the device from the actual brightness to value in the
+// background. A fade already in progress is cancelled first, such that the
+// device always ends up at the value of the latest fade.
+func (d *device) fadeTo(value int, errors chan error) {
+	d.fader.start(func(ctx context.Context) {
+		start, err := d.Get()
+		if err == nil {
+			err = d.Fade(ctx, d.fade, start, value)
+		}
+
+		if err != nil && err != context.Canceled {
+			select {
+			case errors <- err:
+			case <-ctx.Done():
+			}
+		}
+	})
+}
+
+// matches returns true if the device is addressed by target.
+func (d *device) matches(target *IPCTarget) bool {
+	if target.Class != d.class {
+		return false
+	}
+
+	return target.Name == "" || target.Name == d.Name
+}
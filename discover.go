@@ -0,0 +1,177 @@
+package lis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	backlightType = "type"
+	// BacklightAuto is the backlight config value used to auto discover the
+	// backlight device.
+	BacklightAuto = "auto"
+	// BacklightNone is the config value used to disable a device.
+	BacklightNone = "none"
+	// kbdBacklightSuffix is the name suffix of keyboard backlight leds.
+	kbdBacklightSuffix = "::kbd_backlight"
+)
+
+// backlight types in order of preference. firmware interfaces (ACPI/EFI)
+// are preferred over platform specific drivers which are preferred over raw
+// access to the GPU registers.
+var backlightTypes = []string{"firmware", "platform", "raw"}
+
+// aliases for the backlight values supported in older configs.
+var backlightAliases = map[string]string{
+	"intel":  "intel_backlight",
+	"amdgpu": "amdgpu_bl",
+}
+
+// BacklightDevice describes a backlight device found in
+// <sysfs>/class/backlight.
+type BacklightDevice struct {
+	Name string
+	Type string
+}
+
+// priority returns the priority of the backlight device type. Lower is
+// better.
+func (d BacklightDevice) priority() int {
+	for i, t := range backlightTypes {
+		if d.Type == t {
+			return i
+		}
+	}
+	return len(backlightTypes)
+}
+
+// ListBacklights lists all backlight devices found in
+// <sysfs>/class/backlight.
+func ListBacklights(sysfs string) ([]BacklightDevice, error) {
+	dir := classPath(sysfs, subsystemBacklight)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]BacklightDevice, 0, len(entries))
+	for _, e := range entries {
+		typ, err := ioutil.ReadFile(path.Join(dir, e.Name(), backlightType))
+		if err != nil {
+			// skip entries which doesn't look like a backlight device.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		devices = append(devices, BacklightDevice{
+			Name: e.Name(),
+			Type: strings.TrimSpace(string(typ)),
+		})
+	}
+
+	return devices, nil
+}
+
+// DiscoverBacklight finds the best suited backlight device.
+func DiscoverBacklight(sysfs string) (string, error) {
+	devices, err := ListBacklights(sysfs)
+	if err != nil {
+		return "", err
+	}
+
+	if len(devices) == 0 {
+		return "", fmt.Errorf("no backlight devices found in %s", classPath(sysfs, subsystemBacklight))
+	}
+
+	sort.SliceStable(devices, func(i, j int) bool {
+		pi, pj := devices[i].priority(), devices[j].priority()
+		if pi != pj {
+			return pi < pj
+		}
+		return devices[i].Name < devices[j].Name
+	})
+
+	return devices[0].Name, nil
+}
+
+// ResolveBacklight resolves a backlight config value to the name of a
+// device in <sysfs>/class/backlight. The value can either be 'auto', the exact
+// name of a device or one of the legacy aliases 'intel' and 'amdgpu'.
+func ResolveBacklight(sysfs, name string) (string, error) {
+	if name == "" || name == BacklightAuto {
+		return DiscoverBacklight(sysfs)
+	}
+
+	devices, err := ListBacklights(sysfs)
+	if err != nil {
+		return "", err
+	}
+
+	for _, d := range devices {
+		if d.Name == name {
+			return d.Name, nil
+		}
+	}
+
+	if prefix, ok := backlightAliases[name]; ok {
+		for _, d := range devices {
+			if strings.HasPrefix(d.Name, prefix) {
+				return d.Name, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("backlight device '%s' not found in %s", name, classPath(sysfs, subsystemBacklight))
+}
+
+// ListKeyboardBacklights lists all keyboard backlight leds found in
+// <sysfs>/class/leds.
+func ListKeyboardBacklights(sysfs string) ([]string, error) {
+	entries, err := ioutil.ReadDir(classPath(sysfs, subsystemLeds))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), kbdBacklightSuffix) {
+			names = append(names, e.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// ResolveKeyboardBacklight resolves a keyboard config value to the name of
+// a device in <sysfs>/class/leds. If the value is 'auto' the first keyboard
+// backlight is returned, or an empty string if none is available. Otherwise
+// the value must be the exact name of a led device.
+func ResolveKeyboardBacklight(sysfs, name string) (string, error) {
+	if name != BacklightAuto {
+		_, err := os.Stat(path.Join(classPath(sysfs, subsystemLeds), name))
+		if err != nil {
+			return "", fmt.Errorf("keyboard backlight '%s' not found in %s", name, classPath(sysfs, subsystemLeds))
+		}
+		return name, nil
+	}
+
+	names, err := ListKeyboardBacklights(sysfs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if len(names) == 0 {
+		return "", nil
+	}
+
+	return names[0], nil
+}
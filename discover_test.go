@@ -0,0 +1,48 @@
+package lis
+
+import (
+	"testing"
+
+	"github.com/mikkeloscar/lis/internal/sysfstest"
+)
+
+func TestResolveBacklight(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("amdgpu_bl0", "raw", 255, 100)
+	sysfs.AddBacklight("nvidia_0", "raw", 100, 100)
+	sysfs.AddBacklight("acpi_video0", "firmware", 15, 10)
+	sysfs.AddLed("tpacpi::kbd_backlight", 2, 1)
+	sysfs.AddLed("input3::capslock", 1, 0)
+
+	for _, tc := range []struct {
+		config   string
+		expected string
+	}{
+		{config: BacklightAuto, expected: "acpi_video0"},
+		{config: "nvidia_0", expected: "nvidia_0"},
+		{config: "amdgpu", expected: "amdgpu_bl0"},
+	} {
+		name, err := ResolveBacklight(sysfs.Root, tc.config)
+		if err != nil {
+			t.Errorf("should not cause error: %s", err)
+		}
+
+		if name != tc.expected {
+			t.Errorf("expected %s for %s, got %s", tc.expected, tc.config, name)
+		}
+	}
+
+	_, err := ResolveBacklight(sysfs.Root, "intel_backlight")
+	if err == nil {
+		t.Errorf("expected error for missing device")
+	}
+
+	name, err := ResolveKeyboardBacklight(sysfs.Root, BacklightAuto)
+	if err != nil {
+		t.Errorf("should not cause error: %s", err)
+	}
+
+	if name != "tpacpi::kbd_backlight" {
+		t.Errorf("expected tpacpi::kbd_backlight, got %s", name)
+	}
+}
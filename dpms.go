@@ -0,0 +1,61 @@
+package lis
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// x11DPMS sets the DPMS state of the X server. It is nil if lis is built
+// without X11 support.
+var x11DPMS func(on bool) error
+
+// setDPMS turns the displays on or off. The X DPMS extension is used when
+// running under X, otherwise the DPMS property of the DRM connectors is set.
+// If lis can't control the DRM cards, e.g. while a Wayland compositor is
+// running, the backlights of the displays are powered through bl_power
+// instead, which doesn't turn off the display itself.
+func (l *Lis) setDPMS(on bool) error {
+	state := "off"
+	if on {
+		state = "on"
+	}
+
+	if x11DPMS != nil && os.Getenv("DISPLAY") != "" {
+		slog.Info(fmt.Sprintf("Setting X DPMS %s", state))
+		return x11DPMS(on)
+	}
+
+	err := l.drm.set(on)
+	if err == nil {
+		slog.Info(fmt.Sprintf("Setting DRM DPMS %s", state))
+		return nil
+	}
+	slog.Info(fmt.Sprintf("Unable to set DRM DPMS %s, using bl_power: %s", state, err))
+
+	var powered bool
+	for _, d := range l.devices {
+		if !d.bound || d.Subsystem != subsystemBacklight {
+			continue
+		}
+
+		// bl_power can't be written through logind.
+		if d.Writer != nil {
+			slog.Info(fmt.Sprintf("Unable to set DPMS of %s: bl_power is not writable", d.Name))
+			continue
+		}
+
+		slog.Info(fmt.Sprintf("Setting DPMS of %s %s", d.Name, state))
+		err := d.SetPower(on)
+		if err != nil {
+			return fmt.Errorf("failed to set DPMS of %s %s: %s", d.Name, state, err)
+		}
+		powered = true
+	}
+
+	if !powered {
+		return fmt.Errorf("no display to set DPMS %s: %s", state, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package lis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// DefaultDRMDir is the default path to the DRM device dir.
+const DefaultDRMDir = "/dev/dri"
+
+// values of the DPMS property of DRM connectors, see DRM_MODE_DPMS_* in
+// drm/drm_mode.h.
+const (
+	drmModeDPMSOn  = 0
+	drmModeDPMSOff = 3
+)
+
+// DRM_MODE_OBJECT_CONNECTOR
+const drmModeObjectConnector = 0xc0c0c0c0
+
+// drmModeCardRes is struct drm_mode_card_res.
+type drmModeCardRes struct {
+	fbIDPtr         uint64
+	crtcIDPtr       uint64
+	connectorIDPtr  uint64
+	encoderIDPtr    uint64
+	countFbs        uint32
+	countCrtcs      uint32
+	countConnectors uint32
+	countEncoders   uint32
+	minWidth        uint32
+	maxWidth        uint32
+	minHeight       uint32
+	maxHeight       uint32
+}
+
+// drmModeObjGetProperties is struct drm_mode_obj_get_properties.
+type drmModeObjGetProperties struct {
+	propsPtr      uint64
+	propValuesPtr uint64
+	countProps    uint32
+	objID         uint32
+	objType       uint32
+	_             uint32
+}
+
+// drmModeGetProperty is struct drm_mode_get_property.
+type drmModeGetProperty struct {
+	valuesPtr      uint64
+	enumBlobPtr    uint64
+	propID         uint32
+	flags          uint32
+	name           [32]byte
+	countValues    uint32
+	countEnumBlobs uint32
+}
+
+// drmModeObjSetProperty is struct drm_mode_obj_set_property.
+type drmModeObjSetProperty struct {
+	value   uint64
+	propID  uint32
+	objID   uint32
+	objType uint32
+	_       uint32
+}
+
+// ioctl request numbers, encoded as _IOC(_IOC_READ|_IOC_WRITE, 'd', nr, size).
+var (
+	drmIoctlModeGetResources     = drmIOWR(0xa0, unsafe.Sizeof(drmModeCardRes{}))
+	drmIoctlModeGetProperty      = drmIOWR(0xaa, unsafe.Sizeof(drmModeGetProperty{}))
+	drmIoctlModeObjGetProperties = drmIOWR(0xb9, unsafe.Sizeof(drmModeObjGetProperties{}))
+	drmIoctlModeObjSetProperty   = drmIOWR(0xba, unsafe.Sizeof(drmModeObjSetProperty{}))
+)
+
+func drmIOWR(nr, size uintptr) uintptr {
+	return 3<<30 | size<<16 | 'd'<<8 | nr
+}
+
+// drmIoctl runs an ioctl on a DRM card, restarting it if interrupted. Tests
+// replace it to fake the cards.
+var drmIoctl = func(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	for {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg))
+		switch errno {
+		case 0:
+			return nil
+		case syscall.EINTR, syscall.EAGAIN:
+			continue
+		default:
+			return errno
+		}
+	}
+}
+
+// drmDPMS sets the DPMS property of the connectors of the DRM cards in dir.
+// Setting connector properties requires being DRM master, which is only
+// possible while no display server is using the card, e.g. on the console.
+// The cards are kept open while the displays are off, as the console
+// restores its mode when the master closes the card.
+type drmDPMS struct {
+	dir   string
+	cards []*os.File // cards holding the displays off
+}
+
+// set turns the displays of all connectors on or off.
+func (d *drmDPMS) set(on bool) error {
+	cards := d.cards
+	d.cards = nil
+	if cards == nil {
+		var err error
+		cards, err = openDRMCards(d.dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	value := uint64(drmModeDPMSOff)
+	if on {
+		value = drmModeDPMSOn
+	}
+
+	var errs []error
+	var set bool
+	for _, f := range cards {
+		n, err := drmSetDPMS(f, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Name(), err))
+		}
+		set = set || n > 0
+	}
+
+	if on || !set {
+		for _, f := range cards {
+			f.Close()
+		}
+	} else {
+		d.cards = cards
+	}
+
+	if !set {
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+		return fmt.Errorf("no DRM connectors with DPMS found in %s", d.dir)
+	}
+
+	return nil
+}
+
+// openDRMCards opens the DRM cards in dir.
+func openDRMCards(dir string) ([]*os.File, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "card[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var cards []*os.File
+	var errs []error
+	for _, name := range names {
+		f, err := os.OpenFile(name, os.O_RDWR, 0)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		cards = append(cards, f)
+	}
+
+	if len(cards) == 0 {
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+		return nil, fmt.Errorf("no DRM cards found in %s", dir)
+	}
+
+	return cards, nil
+}
+
+// drmSetDPMS sets the DPMS property of the connectors of a card. Returns
+// the number of connectors set.
+func drmSetDPMS(f *os.File, value uint64) (int, error) {
+	connectors, err := drmConnectors(f)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, id := range connectors {
+		prop, err := drmFindProperty(f, id, drmModeObjectConnector, "DPMS")
+		if err != nil {
+			return n, err
+		}
+
+		if prop == 0 {
+			continue
+		}
+
+		set := drmModeObjSetProperty{
+			value:   value,
+			propID:  prop,
+			objID:   id,
+			objType: drmModeObjectConnector,
+		}
+		err = drmIoctl(f, drmIoctlModeObjSetProperty, unsafe.Pointer(&set))
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// drmConnectors returns the ids of the connectors of a card.
+func drmConnectors(f *os.File) ([]uint32, error) {
+	for {
+		var res drmModeCardRes
+		err := drmIoctl(f, drmIoctlModeGetResources, unsafe.Pointer(&res))
+		if err != nil {
+			return nil, err
+		}
+
+		if res.countConnectors == 0 {
+			return nil, nil
+		}
+
+		ids := make([]uint32, res.countConnectors)
+		res = drmModeCardRes{
+			connectorIDPtr:  uint64(uintptr(unsafe.Pointer(&ids[0]))),
+			countConnectors: uint32(len(ids)),
+		}
+		err = drmIoctl(f, drmIoctlModeGetResources, unsafe.Pointer(&res))
+		runtime.KeepAlive(ids)
+		if err != nil {
+			return nil, err
+		}
+
+		// try again if a connector was added in between.
+		if int(res.countConnectors) <= len(ids) {
+			return ids[:res.countConnectors], nil
+		}
+	}
+}
+
+// drmFindProperty returns the id of the property of an object by name, or 0
+// if the object doesn't have the property.
+func drmFindProperty(f *os.File, objID, objType uint32, name string) (uint32, error) {
+	get := drmModeObjGetProperties{objID: objID, objType: objType}
+	err := drmIoctl(f, drmIoctlModeObjGetProperties, unsafe.Pointer(&get))
+	if err != nil || get.countProps == 0 {
+		return 0, err
+	}
+
+	props := make([]uint32, get.countProps)
+	values := make([]uint64, get.countProps)
+	get.propsPtr = uint64(uintptr(unsafe.Pointer(&props[0])))
+	get.propValuesPtr = uint64(uintptr(unsafe.Pointer(&values[0])))
+	err = drmIoctl(f, drmIoctlModeObjGetProperties, unsafe.Pointer(&get))
+	runtime.KeepAlive(props)
+	runtime.KeepAlive(values)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range props[:min(int(get.countProps), len(props))] {
+		prop := drmModeGetProperty{propID: id}
+		err := drmIoctl(f, drmIoctlModeGetProperty, unsafe.Pointer(&prop))
+		if err != nil {
+			return 0, err
+		}
+
+		if string(bytes.TrimRight(prop.name[:], "\x00")) == name {
+			return id, nil
+		}
+	}
+
+	return 0, nil
+}
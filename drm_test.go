@@ -0,0 +1,136 @@
+package lis
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"testing"
+	"unsafe"
+)
+
+// fakeDRMCard fakes the connectors of a DRM card and their properties.
+type fakeDRMCard struct {
+	connectors map[uint32][]uint32 // property ids by connector id
+	props      map[uint32]string   // property names by id
+	dpms       map[uint32]uint64   // DPMS values set by connector id
+}
+
+// drmSlice returns the slice an array pointer of a DRM ioctl points to.
+func drmSlice[T any](ptr uint64, n int) []T {
+	return unsafe.Slice((*T)(*(*unsafe.Pointer)(unsafe.Pointer(&ptr))), n)
+}
+
+func (c *fakeDRMCard) ioctl(_ *os.File, req uintptr, arg unsafe.Pointer) error {
+	switch req {
+	case drmIoctlModeGetResources:
+		res := (*drmModeCardRes)(arg)
+		ids := make([]uint32, 0, len(c.connectors))
+		for id := range c.connectors {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+		if res.connectorIDPtr != 0 {
+			copy(drmSlice[uint32](res.connectorIDPtr, int(res.countConnectors)), ids)
+		}
+		res.countConnectors = uint32(len(ids))
+	case drmIoctlModeObjGetProperties:
+		get := (*drmModeObjGetProperties)(arg)
+		props, ok := c.connectors[get.objID]
+		if !ok || get.objType != drmModeObjectConnector {
+			return fmt.Errorf("unknown object %d", get.objID)
+		}
+
+		if get.propsPtr != 0 {
+			copy(drmSlice[uint32](get.propsPtr, int(get.countProps)), props)
+		}
+		get.countProps = uint32(len(props))
+	case drmIoctlModeGetProperty:
+		prop := (*drmModeGetProperty)(arg)
+		name, ok := c.props[prop.propID]
+		if !ok {
+			return fmt.Errorf("unknown property %d", prop.propID)
+		}
+		copy(prop.name[:], name)
+	case drmIoctlModeObjSetProperty:
+		set := (*drmModeObjSetProperty)(arg)
+		if c.props[set.propID] != "DPMS" {
+			return fmt.Errorf("unexpected property %d", set.propID)
+		}
+		c.dpms[set.objID] = set.value
+	default:
+		return fmt.Errorf("unexpected ioctl %x", req)
+	}
+
+	return nil
+}
+
+func TestDRMDPMS(t *testing.T) {
+	dir := t.TempDir()
+	drm := &drmDPMS{dir: dir}
+
+	if err := drm.set(false); err == nil {
+		t.Errorf("expected error without DRM cards")
+	}
+
+	// a node which is not a DRM card doesn't support the ioctls.
+	err := os.WriteFile(path.Join(dir, "card0"), nil, 0600)
+	if err != nil {
+		t.Fatalf("failed to create card: %s", err)
+	}
+
+	if err := drm.set(false); err == nil {
+		t.Errorf("expected error for invalid DRM card")
+	}
+
+	if len(drm.cards) != 0 {
+		t.Errorf("expected cards to be closed after failing, got %d open", len(drm.cards))
+	}
+
+	// a card with a connector without the DPMS property, e.g. a
+	// writeback connector.
+	card := &fakeDRMCard{
+		connectors: map[uint32][]uint32{
+			31: {1, 2},
+			32: {1},
+			33: {3, 2},
+		},
+		props: map[uint32]string{1: "EDID", 2: "DPMS", 3: "CRTC_ID"},
+		dpms:  map[uint32]uint64{},
+	}
+
+	defer func(ioctl func(*os.File, uintptr, unsafe.Pointer) error) {
+		drmIoctl = ioctl
+	}(drmIoctl)
+	drmIoctl = card.ioctl
+
+	err = drm.set(false)
+	if err != nil {
+		t.Fatalf("failed to turn displays off: %s", err)
+	}
+
+	expected := map[uint32]uint64{31: drmModeDPMSOff, 33: drmModeDPMSOff}
+	if fmt.Sprint(card.dpms) != fmt.Sprint(expected) {
+		t.Errorf("expected DPMS %v, got %v", expected, card.dpms)
+	}
+
+	// the card is kept open while the displays are off.
+	if len(drm.cards) != 1 {
+		t.Errorf("expected card to be kept open, got %d open", len(drm.cards))
+	}
+
+	err = drm.set(true)
+	if err != nil {
+		t.Fatalf("failed to turn displays on: %s", err)
+	}
+
+	expected = map[uint32]uint64{31: drmModeDPMSOn, 33: drmModeDPMSOn}
+	if fmt.Sprint(card.dpms) != fmt.Sprint(expected) {
+		t.Errorf("expected DPMS %v, got %v", expected, card.dpms)
+	}
+
+	if len(drm.cards) != 0 {
+		t.Errorf("expected card to be closed, got %d open", len(drm.cards))
+	}
+}
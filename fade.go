@@ -0,0 +1,178 @@
+package lis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// EasingLinear changes the brightness at a constant rate.
+	EasingLinear = "linear"
+	// EasingEaseIn starts slow and speeds up.
+	EasingEaseIn = "ease-in"
+	// EasingEaseOut starts fast and slows down.
+	EasingEaseOut = "ease-out"
+	// EasingEaseInOut starts and ends slow.
+	EasingEaseInOut = "ease-in-out"
+
+	defaultFadeDuration = 250 * time.Millisecond
+	defaultFadeRate     = 20
+)
+
+// Easing maps the progress of a fade, a value between 0 and 1, to the eased
+// progress.
+type Easing func(t float64) float64
+
+// NewEasing returns the easing function identified by name.
+func NewEasing(name string) (Easing, error) {
+	switch name {
+	case "", EasingLinear:
+		return func(t float64) float64 { return t }, nil
+	case EasingEaseIn:
+		return func(t float64) float64 { return t * t * t }, nil
+	case EasingEaseOut:
+		return func(t float64) float64 { return 1 - math.Pow(1-t, 3) }, nil
+	case EasingEaseInOut:
+		return func(t float64) float64 { return (1 - math.Cos(math.Pi*t)) / 2 }, nil
+	default:
+		return nil, fmt.Errorf("invalid easing: %s", name)
+	}
+}
+
+// Fade describes a fade animation.
+type Fade struct {
+	Duration time.Duration // duration of the fade
+	Rate     int           // max number of frames per second
+	Easing   Easing
+}
+
+// DefaultFade is the fade used if nothing else is configured. Five linear
+// steps 50ms apart.
+var DefaultFade = Fade{
+	Duration: defaultFadeDuration,
+	Rate:     defaultFadeRate,
+	Easing:   func(t float64) float64 { return t },
+}
+
+// frames returns the number of frames and the interval between them for a
+// fade changing the brightness by delta raw units. There is never more
+// frames than raw units to change, such that no frame is wasted on devices
+// with a low max_brightness.
+func (f Fade) frames(delta int) (int, time.Duration) {
+	if delta < 0 {
+		delta = -delta
+	}
+
+	frames := int(f.Duration.Seconds() * float64(f.Rate))
+	if frames > delta {
+		frames = delta
+	}
+
+	if frames < 1 {
+		frames = 1
+	}
+
+	return frames, f.Duration / time.Duration(frames)
+}
+
+// Fade changes the brightness from start to end. The frames are evenly
+// spaced in time and follow the easing function on the brightness curve of
+// the backlight. Returns ctx.Err() if the fade was cancelled before end was
+// reached.
+func (b *Backlight) Fade(ctx context.Context, fade Fade, start, end int) error {
+	if fade.Duration <= 0 || start == end {
+		return b.Set(end)
+	}
+
+	from := b.Curve.FromRaw(start, b.Max)
+	to := b.Curve.FromRaw(end, b.Max)
+
+	frames, interval := fade.frames(end - start)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := start
+	for i := 1; i <= frames; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current := end
+		if i < frames {
+			t := fade.Easing(float64(i) / float64(frames))
+			current = b.Curve.ToRaw(from+(to-from)*t, b.Max)
+		}
+
+		if current == last {
+			continue
+		}
+
+		err := b.Set(current)
+		if err != nil {
+			return err
+		}
+		last = current
+	}
+
+	return nil
+}
+
+// fader runs fade animations on a backlight. Starting a new fade cancels
+// the fade in progress, so only one fade is running at a time.
+type fader struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// start cancels the running fade, if any, and starts fn in a new goroutine.
+func (f *fader) start(fn func(ctx context.Context)) {
+	f.stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	f.cancel = cancel
+	f.done = done
+
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+}
+
+// stop cancels the running fade and waits for it to stop.
+func (f *fader) stop() {
+	if f.cancel == nil {
+		return
+	}
+
+	f.cancel()
+	<-f.done
+	f.cancel = nil
+	f.done = nil
+}
+
+// running returns true if a fade is in progress.
+func (f *fader) running() bool {
+	if f.done == nil {
+		return false
+	}
+
+	select {
+	case <-f.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// wait waits for the running fade to finish.
+func (f *fader) wait() {
+	if f.done != nil {
+		<-f.done
+	}
+}
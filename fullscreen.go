@@ -0,0 +1,66 @@
+package lis
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// wmClass is the WM_CLASS of an X window.
+type wmClass struct {
+	instance string // e.g. 'Navigator'
+	class    string // e.g. 'firefox'
+}
+
+func (c wmClass) String() string {
+	if c.class == "" {
+		return c.instance
+	}
+	return c.class
+}
+
+// x11FullscreenWindow returns the WM_CLASS of the active window if it is
+// fullscreen. It is nil if lis is built without X11 support.
+var x11FullscreenWindow func() (wmClass, bool, error)
+
+// fullscreenAllowed returns true if a fullscreen window of class may
+// inhibit idle. Names match either the instance or the class of the window,
+// ignoring case.
+func fullscreenAllowed(c wmClass, allow, deny []string) bool {
+	if wmClassMatch(c, deny) {
+		return false
+	}
+
+	return len(allow) == 0 || wmClassMatch(c, allow)
+}
+
+// wmClassMatch returns true if c matches one of the names.
+func wmClassMatch(c wmClass, names []string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, c.instance) || strings.EqualFold(n, c.class) {
+			return true
+		}
+	}
+	return false
+}
+
+// fullscreenInhibitor returns a description of the active window if it is
+// fullscreen and inhibits idle, or an empty string otherwise.
+func (l *Lis) fullscreenInhibitor() string {
+	if !l.fullscreen.Enable || x11FullscreenWindow == nil || os.Getenv("DISPLAY") == "" {
+		return ""
+	}
+
+	class, ok, err := x11FullscreenWindow()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to check for fullscreen window: %s", err))
+		return ""
+	}
+
+	if !ok || !fullscreenAllowed(class, l.fullscreen.Allow, l.fullscreen.Deny) {
+		return ""
+	}
+
+	return fmt.Sprintf("%s (x11: fullscreen window)", class)
+}
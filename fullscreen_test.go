@@ -0,0 +1,63 @@
+package lis
+
+import (
+	"testing"
+)
+
+func TestFullscreenAllowed(t *testing.T) {
+	firefox := wmClass{instance: "Navigator", class: "firefox"}
+	for _, tc := range []struct {
+		class    wmClass
+		allow    []string
+		deny     []string
+		expected bool
+	}{
+		{firefox, nil, nil, true},
+		{firefox, []string{"firefox"}, nil, true},
+		{firefox, []string{"navigator"}, nil, true},
+		{firefox, []string{"Firefox"}, nil, true},
+		{firefox, []string{"mpv"}, nil, false},
+		{firefox, nil, []string{"firefox"}, false},
+		{firefox, []string{"firefox"}, []string{"Navigator"}, false},
+		{wmClass{instance: "mpv"}, []string{"mpv"}, nil, true},
+		{wmClass{}, []string{"mpv"}, nil, false},
+	} {
+		allowed := fullscreenAllowed(tc.class, tc.allow, tc.deny)
+		if allowed != tc.expected {
+			t.Errorf("%+v (allow %v, deny %v): expected %t, got %t", tc.class, tc.allow, tc.deny, tc.expected, allowed)
+		}
+	}
+}
+
+func TestFullscreenInhibitor(t *testing.T) {
+	window := func() (wmClass, bool, error) {
+		return wmClass{instance: "Navigator", class: "firefox"}, true, nil
+	}
+
+	defer func(f func() (wmClass, bool, error)) {
+		x11FullscreenWindow = f
+	}(x11FullscreenWindow)
+	x11FullscreenWindow = window
+	t.Setenv("DISPLAY", ":99")
+
+	l := &Lis{}
+	if by := l.fullscreenInhibitor(); by != "" {
+		t.Errorf("expected no inhibitor when disabled, got %s", by)
+	}
+
+	l.fullscreen = FullscreenConfig{Enable: true}
+	if by := l.fullscreenInhibitor(); by != "firefox (x11: fullscreen window)" {
+		t.Errorf("unexpected inhibitor: %s", by)
+	}
+
+	l.fullscreen.Deny = StringList{"firefox"}
+	if by := l.fullscreenInhibitor(); by != "" {
+		t.Errorf("expected denied window not to inhibit, got %s", by)
+	}
+
+	l.fullscreen.Deny = nil
+	t.Setenv("DISPLAY", "")
+	if by := l.fullscreenInhibitor(); by != "" {
+		t.Errorf("expected no inhibitor without X, got %s", by)
+	}
+}
@@ -7,7 +7,6 @@ require (
 	github.com/cenkalti/backoff/v4 v4.2.1
 	github.com/coreos/go-systemd v0.0.0-20191104093116-d3cd4ed1dbcf
 	github.com/godbus/dbus v4.1.0+incompatible
-	github.com/mikkeloscar/evdev v0.0.18
 	github.com/sirupsen/logrus v1.9.3
 )
 
@@ -0,0 +1,129 @@
+package lis
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// resolve resolves the selector of a device to the name of a device in
+// sysfs.
+func (l *Lis) resolve(d *device) (string, error) {
+	if d.class == classKbd {
+		return ResolveKeyboardBacklight(l.sysfs, d.selector)
+	}
+	return ResolveBacklight(l.sysfs, d.selector)
+}
+
+// bind binds the device to the backlight or led device name. If wait is
+// true it waits for the kernel to create the device.
+func (l *Lis) bind(d *device, name string, wait bool) error {
+	var backlight *Backlight
+	var err error
+	if wait {
+		backlight, err = newBacklight(l.sysfs, d.subsystem(), name)
+	} else {
+		backlight, err = openBacklight(l.sysfs, d.subsystem(), name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if d.class == classPanel {
+		backlight.Curve = l.curve
+	}
+
+	err = l.setupWriter(backlight)
+	if err != nil {
+		return err
+	}
+
+	d.fader.stop()
+	d.Backlight = backlight
+	d.bound = true
+
+	return nil
+}
+
+// setupWriter sets up how brightness values are written to a device.
+// logind is used if configured, or if the device is not writable in auto
+// mode.
+func (l *Lis) setupWriter(b *Backlight) error {
+	if l.writer == WriterSysfs || (l.writer != WriterLogind && b.Writable()) {
+		return nil
+	}
+
+	if l.logind == nil {
+		var err error
+		l.logind, err = NewLogindWriter()
+		if err != nil {
+			return err
+		}
+	}
+
+	slog.Info(fmt.Sprintf("Writing brightness of %s through logind", b.Name))
+	b.Writer = l.logind
+	return nil
+}
+
+// unbind marks the device as removed. The device keeps its brightness
+// level, such that it can be restored when the device is bound again.
+func (l *Lis) unbind(d *device) {
+	slog.Info(fmt.Sprintf("Backlight device %s was removed", d.Name))
+	d.fader.stop()
+	d.bound = false
+}
+
+// rebind tries to bind all removed devices again, e.g. after a driver
+// reload or when a device reappears under a new name. The brightness level
+// of the device is restored on the new device. Devices which have not been
+// found at startup are bound with their current brightness.
+func (l *Lis) rebind() {
+	for _, d := range l.devices {
+		if d.bound {
+			continue
+		}
+
+		name, err := l.resolve(d)
+		if err != nil || name == "" {
+			continue
+		}
+
+		// don't bind the same device twice.
+		if l.device(d.subsystem(), name) != nil {
+			continue
+		}
+
+		// keep the brightness level relative to the brightness curve in
+		// case the new device has a different max brightness.
+		restore := d.Backlight != nil
+		var level float64
+		if restore {
+			level = d.Curve.FromRaw(int(d.current), d.Max)
+		}
+
+		err = l.bind(d, name, false)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to bind backlight device %s: %s", name, err))
+			continue
+		}
+
+		slog.Info(fmt.Sprintf("Using backlight device: %s", name))
+
+		if restore {
+			d.current = uint16(d.clamp(d.Curve.ToRaw(level, d.Max)))
+		} else {
+			err = d.refresh()
+			if err != nil {
+				slog.Error(fmt.Sprintf("Failed to get brightness value of %s: %s", name, err))
+				continue
+			}
+		}
+
+		value := l.idleLevel(d)
+
+		err = d.Set(value)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to restore brightness of %s: %s", name, err))
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package lis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// IdleSourceAuto picks the best idle source for the environment.
+	IdleSourceAuto = "auto"
+	// IdleSourceX11 detects idle through the XSync IDLETIME counter of the
+	// X server.
+	IdleSourceX11 = "x11"
+	// IdleSourceLogind detects idle through the logind session IdleHint.
+	IdleSourceLogind = "logind"
+	// IdleSourceEvdev detects idle by monitoring the input devices.
+	IdleSourceEvdev = "evdev"
+	// IdleSourceWayland detects idle through the idle notification
+	// protocol of the Wayland compositor.
+	IdleSourceWayland = "wayland"
+)
+
+// ErrActivityUnsupported is returned by IdleSource.WaitActive if the source
+// can't detect when the user becomes active. lis falls back to monitoring
+// the input devices in that case.
+var ErrActivityUnsupported = errors.New("idle source can't detect activity")
+
+// IdleSource detects when the user is idle.
+type IdleSource interface {
+	// WaitIdle blocks until the user has been idle for at least timeout
+	// or ctx is cancelled.
+	WaitIdle(ctx context.Context, timeout time.Duration) error
+	// WaitActive blocks until the user is active or ctx is cancelled.
+	WaitActive(ctx context.Context) error
+	// Close releases the resources held by the source.
+	Close() error
+}
+
+// IdleSourceOptions holds the settings passed to idle sources.
+type IdleSourceOptions struct {
+	// Inputs returns the input devices shared with lis, opened on first
+	// use.
+	Inputs func() (*InputManager, error)
+}
+
+// idleSources holds the idle sources compiled into lis. Sources register
+// themselves from init functions, such that sources depending on optional
+// libraries can be left out with build tags.
+var idleSources = map[string]func(opts IdleSourceOptions) (IdleSource, error){}
+
+// autoIdleSources is the order in which idle sources are tried in auto
+// mode along with a check of whether the source is usable in the
+// environment. Sources failing to initialize, e.g. a compositor without
+// idle notification or no readable input devices, fall back to the next.
+var autoIdleSources = []struct {
+	name   string
+	usable func() bool
+}{
+	// Wayland sessions may set DISPLAY for Xwayland, which only sees
+	// activity in X clients.
+	{IdleSourceWayland, func() bool { return os.Getenv("WAYLAND_DISPLAY") != "" }},
+	{IdleSourceX11, func() bool { return os.Getenv("DISPLAY") != "" }},
+	// the IdleHint of logind is only maintained by some display
+	// managers and desktops, not on the console.
+	{IdleSourceEvdev, func() bool { return true }},
+	{IdleSourceLogind, func() bool { return true }},
+}
+
+// NewIdleSource initializes the idle source identified by name.
+func NewIdleSource(name string, opts IdleSourceOptions) (IdleSource, error) {
+	if name == IdleSourceAuto {
+		return newAutoIdleSource(opts)
+	}
+
+	newSource, ok := idleSources[name]
+	if !ok {
+		return nil, fmt.Errorf("idle source '%s' not supported, must be one of: %s", name, strings.Join(IdleSources(), ", "))
+	}
+
+	return newSource(opts)
+}
+
+// newAutoIdleSource initializes the first usable idle source in auto order.
+func newAutoIdleSource(opts IdleSourceOptions) (IdleSource, error) {
+	var errs []error
+	for _, s := range autoIdleSources {
+		newSource, ok := idleSources[s.name]
+		if !ok || !s.usable() {
+			continue
+		}
+
+		source, err := newSource(opts)
+		if err != nil {
+			slog.Info(fmt.Sprintf("Idle source %s not usable: %s", s.name, err))
+			errs = append(errs, err)
+			continue
+		}
+
+		slog.Info(fmt.Sprintf("Using idle source: %s", s.name))
+		return source, nil
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return nil, fmt.Errorf("no idle source usable, must be one of: %s", strings.Join(IdleSources(), ", "))
+}
+
+// IdleSources returns the names of the idle sources compiled into lis.
+func IdleSources() []string {
+	names := make([]string, 0, len(idleSources))
+	for name := range idleSources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
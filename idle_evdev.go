@@ -0,0 +1,59 @@
+package lis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func init() {
+	idleSources[IdleSourceEvdev] = newEvdevIdle
+}
+
+// evdevIdle detects idle by monitoring the input devices for key, relative
+// and absolute events. It doesn't depend on a display server and works on
+// the console as well as on Wayland.
+type evdevIdle struct {
+	inputs *InputManager
+}
+
+func newEvdevIdle(opts IdleSourceOptions) (IdleSource, error) {
+	inputs, err := opts.Inputs()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(inputs.devices(false)) == 0 {
+		return nil, fmt.Errorf("evdev: no input devices found in %s", inputs.dir)
+	}
+
+	return &evdevIdle{inputs: inputs}, nil
+}
+
+// WaitIdle waits until there has been no input events for timeout.
+func (s *evdevIdle) WaitIdle(ctx context.Context, timeout time.Duration) error {
+	for {
+		remaining := timeout - time.Since(s.inputs.LastActivity())
+		if remaining <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// WaitActive waits for the next input event.
+func (s *evdevIdle) WaitActive(ctx context.Context) error {
+	return s.inputs.Wait(ctx)
+}
+
+// Close does nothing, the input devices are closed by lis.
+func (s *evdevIdle) Close() error {
+	return nil
+}
@@ -0,0 +1,63 @@
+package lis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEvdevIdle(t *testing.T) {
+	inputs := newInputManager("")
+	s := &evdevIdle{inputs: inputs}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// input events postpone idle.
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(50 * time.Millisecond)
+			inputs.activity(false)
+		}
+	}()
+
+	start := time.Now()
+	err := s.WaitIdle(ctx, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitIdle failed: %s", err)
+	}
+
+	if d := time.Since(start); d < 250*time.Millisecond {
+		t.Errorf("expected idle after at least 250ms, got %s", d)
+	}
+
+	active := make(chan error, 1)
+	go func() {
+		active <- s.WaitActive(ctx)
+	}()
+
+	select {
+	case err := <-active:
+		t.Fatalf("WaitActive returned without input: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	inputs.activity(false)
+
+	select {
+	case err := <-active:
+		if err != nil {
+			t.Fatalf("WaitActive failed: %s", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("WaitActive did not detect input")
+	}
+
+	cctx, ccancel := context.WithCancel(context.Background())
+	ccancel()
+	err = s.WaitIdle(cctx, time.Hour)
+	if err != context.Canceled {
+		t.Errorf("expected WaitIdle to be cancelled, got: %v", err)
+	}
+}
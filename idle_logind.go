@@ -0,0 +1,151 @@
+package lis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus"
+)
+
+const dbusPropertiesIface = "org.freedesktop.DBus.Properties"
+
+func init() {
+	idleSources[IdleSourceLogind] = newLogindIdle
+}
+
+// logindIdle detects idle through the IdleHint of the logind session. The
+// IdleHint is set by the desktop environment, or for text sessions by
+// logind itself based on tty activity.
+type logindIdle struct {
+	conn    *dbus.Conn
+	session dbus.BusObject
+	signals chan *dbus.Signal
+	changed chan struct{}
+}
+
+func newLogindIdle(IdleSourceOptions) (IdleSource, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %s", err)
+	}
+
+	// resolve the path of the session, signals are not emitted on the
+	// 'auto' path.
+	var id string
+	err = conn.Object(login1Dest, login1SessionAuto).Call(dbusPropertiesIface+".Get", 0, login1SessionIface, "Id").Store(&id)
+	if err != nil {
+		return nil, fmt.Errorf("logind: failed to get session: %s", err)
+	}
+
+	var path dbus.ObjectPath
+	err = conn.Object(login1Dest, login1Path).Call(login1ManagerIface+".GetSession", 0, id).Store(&path)
+	if err != nil {
+		return nil, fmt.Errorf("logind: failed to get session %s: %s", id, err)
+	}
+
+	rule := fmt.Sprintf("type='signal',sender='%s',interface='%s',member='PropertiesChanged',path='%s'", login1Dest, dbusPropertiesIface, path)
+	call := conn.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, rule)
+	if call.Err != nil {
+		return nil, fmt.Errorf("logind: failed to subscribe to session: %s", call.Err)
+	}
+
+	s := &logindIdle{
+		conn:    conn,
+		session: conn.Object(login1Dest, path),
+		signals: make(chan *dbus.Signal, 10),
+		changed: make(chan struct{}, 1),
+	}
+
+	conn.Signal(s.signals)
+	go s.watch()
+
+	return s, nil
+}
+
+// watch notifies about property changes of the session.
+func (s *logindIdle) watch() {
+	for signal := range s.signals {
+		if signal.Path != s.session.Path() || signal.Name != dbusPropertiesIface+".PropertiesChanged" {
+			continue
+		}
+
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// idleHint returns the IdleHint of the session and the time it was set.
+func (s *logindIdle) idleHint() (bool, time.Time, error) {
+	hint, err := s.session.GetProperty(login1SessionIface + ".IdleHint")
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("logind: failed to get IdleHint: %s", err)
+	}
+
+	since, err := s.session.GetProperty(login1SessionIface + ".IdleSinceHint")
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("logind: failed to get IdleSinceHint: %s", err)
+	}
+
+	idle, _ := hint.Value().(bool)
+	usec, _ := since.Value().(uint64)
+
+	return idle, time.Unix(0, int64(usec)*int64(time.Microsecond)), nil
+}
+
+// WaitIdle blocks until the session has been idle for at least timeout.
+func (s *logindIdle) WaitIdle(ctx context.Context, timeout time.Duration) error {
+	for {
+		idle, since, err := s.idleHint()
+		if err != nil {
+			return err
+		}
+
+		// if the session is not idle, check again on the next change or
+		// after timeout in case the change was missed.
+		wait := timeout
+		if idle {
+			idleTime := time.Since(since)
+			if idleTime >= timeout {
+				return nil
+			}
+			wait = timeout - idleTime
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.changed:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// WaitActive blocks until the IdleHint of the session is cleared.
+func (s *logindIdle) WaitActive(ctx context.Context) error {
+	for {
+		idle, _, err := s.idleHint()
+		if err != nil {
+			return err
+		}
+
+		if !idle {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.changed:
+		}
+	}
+}
+
+// Close stops listening for session changes.
+func (s *logindIdle) Close() error {
+	s.conn.RemoveSignal(s.signals)
+	close(s.signals)
+	return nil
+}
@@ -0,0 +1,73 @@
+package lis
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// namedIdle is a fake idle source identified by name.
+type namedIdle string
+
+func (namedIdle) WaitIdle(context.Context, time.Duration) error { return nil }
+func (namedIdle) WaitActive(context.Context) error              { return nil }
+func (namedIdle) Close() error                                  { return nil }
+
+func TestNewIdleSourceAuto(t *testing.T) {
+	sources := idleSources
+	defer func() { idleSources = sources }()
+
+	var failing map[string]bool
+	idleSources = map[string]func(IdleSourceOptions) (IdleSource, error){}
+	for _, name := range []string{IdleSourceWayland, IdleSourceX11, IdleSourceEvdev, IdleSourceLogind} {
+		name := name
+		idleSources[name] = func(IdleSourceOptions) (IdleSource, error) {
+			if failing[name] {
+				return nil, fmt.Errorf("%s failed", name)
+			}
+			return namedIdle(name), nil
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		wayland  string
+		display  string
+		failing  []string
+		expected string
+	}{
+		{"wayland", "wayland-0", ":0", nil, IdleSourceWayland},
+		{"wayland without idle notification", "wayland-0", ":0", []string{IdleSourceWayland}, IdleSourceX11},
+		{"wayland without Xwayland", "wayland-0", "", []string{IdleSourceWayland}, IdleSourceEvdev},
+		{"x11", "", ":0", nil, IdleSourceX11},
+		{"console", "", "", nil, IdleSourceEvdev},
+		{"console without input devices", "", "", []string{IdleSourceEvdev}, IdleSourceLogind},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("WAYLAND_DISPLAY", tc.wayland)
+			t.Setenv("DISPLAY", tc.display)
+
+			failing = map[string]bool{}
+			for _, name := range tc.failing {
+				failing[name] = true
+			}
+
+			source, err := NewIdleSource(IdleSourceAuto, IdleSourceOptions{})
+			if err != nil {
+				t.Fatalf("failed to create idle source: %s", err)
+			}
+
+			if source != namedIdle(tc.expected) {
+				t.Errorf("expected idle source %s, got %s", tc.expected, source)
+			}
+		})
+	}
+
+	failing = map[string]bool{IdleSourceEvdev: true, IdleSourceLogind: true}
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", "")
+	if _, err := NewIdleSource(IdleSourceAuto, IdleSourceOptions{}); err == nil {
+		t.Errorf("expected error when no idle source is usable")
+	}
+}
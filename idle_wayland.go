@@ -0,0 +1,280 @@
+package lis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	wlSeat          = "wl_seat"
+	extIdleNotifier = "ext_idle_notifier_v1"
+	kdeIdle         = "org_kde_kwin_idle"
+
+	// ext_idle_notifier_v1.get_idle_notification
+	extIdleNotifierGetIdleNotification = 1
+	// ext_idle_notification_v1.destroy
+	extIdleNotificationDestroy = 0
+	// org_kde_kwin_idle.get_idle_timeout
+	kdeIdleGetIdleTimeout = 0
+	// org_kde_kwin_idle_timeout.release
+	kdeIdleTimeoutRelease = 0
+
+	// idled/idle and resumed events shared by ext_idle_notification_v1 and
+	// org_kde_kwin_idle_timeout.
+	wlIdleIdled   = 0
+	wlIdleResumed = 1
+)
+
+func init() {
+	idleSources[IdleSourceWayland] = newWaylandIdle
+}
+
+// wlIdleNotification is an idle notification object for a single timeout.
+type wlIdleNotification struct {
+	id   uint32
+	idle bool
+}
+
+// waylandIdle detects idle and activity through the ext-idle-notify-v1
+// protocol of the Wayland compositor, falling back to the KDE idle
+// protocol.
+type waylandIdle struct {
+	mu            sync.Mutex
+	conn          *wlConn
+	err           error // reason the last connection was lost
+	seat          uint32
+	notifier      uint32
+	kde           bool // notifier is org_kde_kwin_idle
+	notifications map[time.Duration]*wlIdleNotification
+	current       *wlIdleNotification // notification of the last WaitIdle
+	changed       chan struct{}       // closed on events and connection loss
+}
+
+// newWaylandIdle connects to the compositor, such that compositors
+// supporting neither idle protocol are detected up front and auto mode can
+// fall back to another source.
+func newWaylandIdle(IdleSourceOptions) (IdleSource, error) {
+	s := &waylandIdle{changed: make(chan struct{})}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// connect connects to the compositor if not already connected. After
+// losing the connection, e.g. when the compositor restarts, it reconnects
+// on the next wait. Must be called with s.mu held.
+func (s *waylandIdle) connect() error {
+	if s.conn != nil {
+		return nil
+	}
+
+	conn, err := dialWayland()
+	if err != nil {
+		return err
+	}
+
+	registry, globals, err := conn.globals()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	seat, ok := globals[wlSeat]
+	if !ok {
+		conn.Close()
+		return fmt.Errorf("wayland: compositor has no seat")
+	}
+
+	iface := extIdleNotifier
+	notifier, ok := globals[iface]
+	if !ok {
+		iface = kdeIdle
+		notifier, ok = globals[iface]
+	}
+	if !ok {
+		conn.Close()
+		return fmt.Errorf("wayland: compositor supports neither %s nor %s", extIdleNotifier, kdeIdle)
+	}
+
+	s.seat, err = conn.bind(registry, wlSeat, seat, 1)
+	if err == nil {
+		s.notifier, err = conn.bind(registry, iface, notifier, 1)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	s.conn = conn
+	s.kde = iface == kdeIdle
+	s.notifications = make(map[time.Duration]*wlIdleNotification)
+	s.current = nil
+
+	go s.run(conn)
+
+	return nil
+}
+
+// run handles the events of conn until the connection is closed.
+func (s *waylandIdle) run(conn *wlConn) {
+	for {
+		msg, err := conn.read()
+		if err == nil && msg.sender == wlDisplayID && msg.opcode == wlDisplayError {
+			err = wlError(msg)
+		}
+
+		s.mu.Lock()
+		if err != nil {
+			if s.conn == conn {
+				conn.Close()
+				s.conn = nil
+				s.err = fmt.Errorf("wayland: lost connection to compositor: %s", err)
+				s.signal()
+			}
+			s.mu.Unlock()
+			return
+		}
+
+		for _, n := range s.notifications {
+			if n.id != msg.sender {
+				continue
+			}
+
+			switch msg.opcode {
+			case wlIdleIdled:
+				n.idle = true
+			case wlIdleResumed:
+				n.idle = false
+			}
+			s.signal()
+		}
+		s.mu.Unlock()
+	}
+}
+
+// signal wakes up waiters. Must be called with s.mu held.
+func (s *waylandIdle) signal() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// notification returns the idle notification for timeout, creating it if
+// needed. Must be called with s.mu held.
+func (s *waylandIdle) notification(timeout time.Duration) (*wlIdleNotification, error) {
+	if n, ok := s.notifications[timeout]; ok {
+		return n, nil
+	}
+
+	n := &wlIdleNotification{id: s.conn.newID()}
+	ms := uint32(timeout.Milliseconds())
+
+	var err error
+	if s.kde {
+		err = s.conn.send(s.notifier, kdeIdleGetIdleTimeout, n.id, s.seat, ms)
+	} else {
+		err = s.conn.send(s.notifier, extIdleNotifierGetIdleNotification, n.id, ms, s.seat)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifications[timeout] = n
+	return n, nil
+}
+
+// wait waits until done returns true, ctx is cancelled or the connection
+// is lost. Must be called with s.mu held.
+func (s *waylandIdle) wait(ctx context.Context, done func() bool) error {
+	conn := s.conn
+	for {
+		if s.conn != conn {
+			return s.err
+		}
+
+		if done() {
+			return nil
+		}
+
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			return ctx.Err()
+		case <-changed:
+		}
+		s.mu.Lock()
+	}
+}
+
+// WaitIdle waits for the compositor to report the user idle for timeout.
+func (s *waylandIdle) WaitIdle(ctx context.Context, timeout time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.connect()
+	if err != nil {
+		return err
+	}
+
+	n, err := s.notification(timeout)
+	if err != nil {
+		return err
+	}
+	s.current = n
+
+	return s.wait(ctx, func() bool { return n.idle })
+}
+
+// WaitActive waits for the compositor to report that the user resumed
+// after the last WaitIdle.
+func (s *waylandIdle) WaitActive(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		if s.err != nil {
+			return s.err
+		}
+		return fmt.Errorf("wayland: not connected to compositor")
+	}
+
+	n := s.current
+	if n == nil {
+		return ErrActivityUnsupported
+	}
+
+	return s.wait(ctx, func() bool { return !n.idle })
+}
+
+// Close destroys the notifications and closes the connection.
+func (s *waylandIdle) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	opcode := uint16(extIdleNotificationDestroy)
+	if s.kde {
+		opcode = kdeIdleTimeoutRelease
+	}
+
+	for _, n := range s.notifications {
+		s.conn.send(n.id, opcode)
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
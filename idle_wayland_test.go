@@ -0,0 +1,161 @@
+package lis
+
+import (
+	"context"
+	"net"
+	"path"
+	"testing"
+	"time"
+)
+
+// wlStub is a minimal Wayland compositor announcing a seat and an idle
+// notifier.
+type wlStub struct {
+	t             *testing.T
+	listener      net.Listener
+	globals       []string
+	conn          *wlConn
+	notifier      uint32
+	notifications chan uint32 // ids of created idle notifications
+}
+
+func startWlStub(t *testing.T, globals ...string) *wlStub {
+	t.Helper()
+
+	dir := t.TempDir()
+	listener, err := net.Listen("unix", path.Join(dir, "wayland-0"))
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	t.Setenv("WAYLAND_DISPLAY", "wayland-0")
+
+	stub := &wlStub{
+		t:             t,
+		listener:      listener,
+		globals:       globals,
+		notifications: make(chan uint32, 10),
+	}
+	go stub.serve()
+
+	return stub
+}
+
+func (s *wlStub) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	s.conn = newWlConn(conn)
+	defer conn.Close()
+
+	var registry uint32
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			return
+		}
+
+		switch {
+		case msg.sender == wlDisplayID && msg.opcode == wlDisplayGetRegistry:
+			registry, _ = msg.uint32()
+			for i, iface := range s.globals {
+				s.conn.send(registry, wlRegistryGlobal, uint32(i+1), iface, uint32(1))
+			}
+		case msg.sender == wlDisplayID && msg.opcode == wlDisplaySync:
+			callback, _ := msg.uint32()
+			s.conn.send(callback, wlCallbackDone, uint32(0))
+		case msg.sender == registry && msg.opcode == wlRegistryBind:
+			msg.uint32()
+			iface, _ := msg.string()
+			msg.uint32()
+			id, _ := msg.uint32()
+			if iface == extIdleNotifier || iface == kdeIdle {
+				s.notifier = id
+			}
+		case msg.sender == s.notifier:
+			id, _ := msg.uint32()
+			s.notifications <- id
+		}
+	}
+}
+
+func TestWaylandIdle(t *testing.T) {
+	for _, globals := range [][]string{
+		{wlSeat, kdeIdle, extIdleNotifier},
+		{wlSeat, kdeIdle},
+	} {
+		t.Run(globals[len(globals)-1], func(t *testing.T) {
+			stub := startWlStub(t, globals...)
+
+			source, err := newWaylandIdle(IdleSourceOptions{})
+			if err != nil {
+				t.Fatalf("failed to create wayland idle source: %s", err)
+			}
+			defer source.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			idle := make(chan error, 1)
+			go func() {
+				idle <- source.WaitIdle(ctx, time.Minute)
+			}()
+
+			var id uint32
+			select {
+			case id = <-stub.notifications:
+			case <-ctx.Done():
+				t.Fatal("no idle notification created")
+			}
+
+			stub.conn.send(id, wlIdleIdled)
+			if err := <-idle; err != nil {
+				t.Fatalf("WaitIdle failed: %s", err)
+			}
+
+			active := make(chan error, 1)
+			go func() {
+				active <- source.WaitActive(ctx)
+			}()
+
+			select {
+			case err := <-active:
+				t.Fatalf("WaitActive returned before resumed: %v", err)
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			stub.conn.send(id, wlIdleResumed)
+			if err := <-active; err != nil {
+				t.Fatalf("WaitActive failed: %s", err)
+			}
+
+			// the notification is reused for the same timeout.
+			go func() {
+				idle <- source.WaitIdle(ctx, time.Minute)
+			}()
+
+			stub.conn.send(id, wlIdleIdled)
+			if err := <-idle; err != nil {
+				t.Fatalf("WaitIdle failed: %s", err)
+			}
+
+			select {
+			case id := <-stub.notifications:
+				t.Errorf("unexpected notification %d", id)
+			default:
+			}
+		})
+	}
+}
+
+func TestWaylandIdleUnsupported(t *testing.T) {
+	startWlStub(t, wlSeat)
+
+	_, err := newWaylandIdle(IdleSourceOptions{})
+	if err == nil {
+		t.Fatal("expected error without idle notifier")
+	}
+}
@@ -0,0 +1,284 @@
+package lis
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// interval for checking whether held back idle stages are still inhibited.
+// logind doesn't signal changes of its inhibitor locks.
+const inhibitCheckInterval = 30 * time.Second
+
+// inhibitor prevents lis from entering the idle stages while held.
+type inhibitor struct {
+	id      uint32
+	source  string // what created the inhibitor e.g. 'screensaver'
+	owner   string // holder of the inhibitor e.g. the unique name of a D-Bus peer
+	app     string // name of the inhibiting application
+	reason  string
+	expires time.Time // time the inhibitor is released, zero if held until released
+}
+
+func (i inhibitor) String() string {
+	s := fmt.Sprintf("%s: %s", i.source, i.reason)
+	if i.app != "" {
+		s = fmt.Sprintf("%s (%s)", i.app, s)
+	}
+
+	if !i.expires.IsZero() {
+		left := time.Until(i.expires).Round(time.Second)
+		if left < 0 {
+			left = 0
+		}
+		s += fmt.Sprintf(" [%s left]", left)
+	}
+
+	return s
+}
+
+// inhibitors is the set of held inhibitors. It is safe for concurrent use.
+type inhibitors struct {
+	mu      sync.Mutex
+	next    uint32
+	held    map[uint32]inhibitor
+	changed chan struct{} // receives a value when inhibitors are released
+}
+
+func newInhibitors() *inhibitors {
+	return &inhibitors{
+		held:    make(map[uint32]inhibitor),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// add adds an inhibitor and returns its id.
+func (i *inhibitors) add(source, owner, app, reason string) uint32 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.next++
+	i.held[i.next] = inhibitor{
+		id:     i.next,
+		source: source,
+		owner:  owner,
+		app:    app,
+		reason: reason,
+	}
+
+	return i.next
+}
+
+// addTimed adds an inhibitor which is released after d and returns its id.
+func (i *inhibitors) addTimed(source, app, reason string, d time.Duration) uint32 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.next++
+	id := i.next
+	i.held[id] = inhibitor{
+		id:      id,
+		source:  source,
+		app:     app,
+		reason:  reason,
+		expires: time.Now().Add(d),
+	}
+
+	time.AfterFunc(d, func() {
+		i.remove(source, id)
+	})
+
+	return id
+}
+
+// remove releases the inhibitor id created by source. Returns false if not
+// found.
+func (i *inhibitors) remove(source string, id uint32) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	inh, ok := i.held[id]
+	if !ok || inh.source != source {
+		return false
+	}
+
+	delete(i.held, id)
+	i.notify()
+	return true
+}
+
+// removeOwner releases all inhibitors created by source for owner and
+// returns the number of released inhibitors.
+func (i *inhibitors) removeOwner(source, owner string) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	n := 0
+	for id, inh := range i.held {
+		if inh.source == source && inh.owner == owner {
+			delete(i.held, id)
+			n++
+		}
+	}
+
+	if n > 0 {
+		i.notify()
+	}
+
+	return n
+}
+
+// list returns the held inhibitors ordered by id.
+func (i *inhibitors) list() []inhibitor {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	list := make([]inhibitor, 0, len(i.held))
+	for _, inh := range i.held {
+		list = append(list, inh)
+	}
+
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].id < list[b].id
+	})
+
+	return list
+}
+
+// notify notifies the main loop about released inhibitors without
+// blocking. Must be called with i.mu held.
+func (i *inhibitors) notify() {
+	select {
+	case i.changed <- struct{}{}:
+	default:
+	}
+}
+
+// inhibitedBy returns a description of an inhibitor preventing the idle
+// stages, or an empty string if idle is not inhibited.
+func (l *Lis) inhibitedBy() string {
+	if list := l.inhibitors.list(); len(list) > 0 {
+		return list[0].String()
+	}
+
+	if by := l.fullscreenInhibitor(); by != "" {
+		return by
+	}
+
+	locks, err := l.logindInhibitors()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to check for idle inhibitors: %s", err))
+		return ""
+	}
+
+	if len(locks) > 0 {
+		return fmt.Sprintf("%s (logind: %s)", locks[0].Who, locks[0].Why)
+	}
+
+	return ""
+}
+
+// handleInhibit handles the INHIBIT, UNINHIBIT and release commands of the
+// IPC server.
+func (l *Lis) handleInhibit(ipc IPCCmd) {
+	switch ipc.typ {
+	case IPCInhibit:
+		inhibit := ipc.val.(ipcInhibit)
+		var id uint32
+		if inhibit.duration > 0 {
+			id = l.inhibitors.addTimed(inhibitIPC, "", inhibit.reason, inhibit.duration)
+			slog.Info(fmt.Sprintf("Idle inhibited for %s: %s", inhibit.duration, inhibit.reason))
+		} else {
+			id = l.inhibitors.add(inhibitIPC, inhibit.owner, "", inhibit.reason)
+			slog.Info(fmt.Sprintf("Idle inhibited by %s: %s", inhibit.owner, inhibit.reason))
+		}
+		ipc.resp <- id
+	case IPCUnInhibit:
+		id := ipc.val.(uint32)
+		if !l.inhibitors.remove(inhibitIPC, id) {
+			ipc.resp <- fmt.Errorf("no such inhibitor: %d", id)
+			return
+		}
+		ipc.resp <- nil
+	case IPCRelease:
+		owner := ipc.val.(string)
+		if n := l.inhibitors.removeOwner(inhibitIPC, owner); n > 0 {
+			slog.Info(fmt.Sprintf("Released %d inhibitor(s) of %s", n, owner))
+		}
+		ipc.resp <- nil
+	}
+}
+
+// holdTimeout returns a channel which fires when held back idle stages
+// should be checked again, or nil if no stage is held back.
+func (l *Lis) holdTimeout() <-chan time.Time {
+	if l.holdTimer == nil {
+		return nil
+	}
+	return l.holdTimer.C
+}
+
+// checkHeld continues the idle stages if they are no longer inhibited. The
+// idle time starts over when the inhibitors are released.
+func (l *Lis) checkHeld(ctx context.Context) {
+	if !l.held {
+		return
+	}
+
+	if l.inhibitedBy() != "" {
+		l.hold()
+		return
+	}
+
+	slog.Info("Idle no longer inhibited")
+	l.unhold()
+	l.activityAt.Store(time.Now().UnixNano())
+
+	if !l.isIdle() {
+		l.idleListener(ctx)
+		return
+	}
+
+	l.stageAt = time.Now()
+	l.stageTimer = time.NewTimer(l.stages[l.stage].timeout - l.stages[l.stage-1].timeout)
+}
+
+// hold holds back the idle stages until the inhibitors are checked again.
+func (l *Lis) hold() {
+	if l.holdTimer != nil {
+		l.holdTimer.Stop()
+	}
+	l.held = true
+	l.holdTimer = time.NewTimer(inhibitCheckInterval)
+}
+
+// unhold stops holding back the idle stages.
+func (l *Lis) unhold() {
+	if l.holdTimer != nil {
+		l.holdTimer.Stop()
+		l.holdTimer = nil
+	}
+	l.held = false
+}
+
+// simulateUserActivity notifies the main loop about simulated activity
+// without blocking. Safe to call from any goroutine.
+func (l *Lis) simulateUserActivity() {
+	select {
+	case l.activity <- struct{}{}:
+	default:
+	}
+}
+
+// simulateActivity resets the idle time as if the user was active.
+func (l *Lis) simulateActivity(ctx context.Context) {
+	l.activityAt.Store(time.Now().UnixNano())
+
+	if l.isIdle() || l.held {
+		l.resume()
+		l.idleListener(ctx)
+	}
+}
@@ -1,147 +1,396 @@
 package lis
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
-
-	"github.com/mikkeloscar/evdev"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
 )
 
 const (
-	deviceDir = "/dev/input/"
+	// DefaultInputDir is the default path to the input device dir.
+	DefaultInputDir = "/dev/input"
 )
 
-// this way we can compare evt.Type to defined eventtypes
+// event types of input events.
 const (
-	evKeys = uint16(evdev.EvKeys)
-	evRel  = uint16(evdev.EvRelative)
+	evKeys = uint16(0x01)
+	evRel  = uint16(0x02)
+	evAbs  = uint16(0x03)
 )
 
+// size of struct input_event, a struct timeval followed by the type, code
+// and value of the event.
+var inputEventSize = int(unsafe.Sizeof(syscall.Timeval{})) + 8
+
+// inputEvent is an event read from an input device.
+type inputEvent struct {
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// parseInputEvent parses a struct input_event.
+func parseInputEvent(b []byte) inputEvent {
+	off := inputEventSize - 8
+	return inputEvent{
+		Type:  binary.NativeEndian.Uint16(b[off:]),
+		Code:  binary.NativeEndian.Uint16(b[off+2:]),
+		Value: int32(binary.NativeEndian.Uint32(b[off+4:])),
+	}
+}
+
+// inputDev is a classified input device.
 type inputDev struct {
+	name    string
 	devPath string
-	stop    chan struct{}
-	errors  chan error
+	phys    string // physical path e.g. usb-0000:00:14.0-2/input0
+	vendor  uint16
+	product uint16
+	class   string
+	action  string      // how the events of the device are treated
+	file    *os.File    // device node, nil if ignored
+	motion  motionState // relative motion accumulated by the filter
+}
+
+func (d *inputDev) String() string {
+	phys := d.phys
+	if phys == "" {
+		phys = "-"
+	}
+	return fmt.Sprintf("%s %s %s %04x:%04x %s %s", d.devPath, d.class, d.action, d.vendor, d.product, phys, d.name)
 }
 
-// InputDevs defines a map of valid input devices.
-type InputDevs struct {
-	devs     map[string]*inputDev
-	Activity chan struct{}
-	errors   chan error
+// InputManager keeps the input devices in a dir open and reports their
+// activity. Each device is classified once when opened, devices connected
+// or removed later are attached and detached automatically. Activity is
+// only reported while notifying, events of paused devices are dropped.
+type InputManager struct {
+	dir     string
+	rules   []InputRule
+	filter  ActivityFilter
+	mu      sync.Mutex
+	devs    map[string]*inputDev // open input devices by device path
+	ignored map[string]*inputDev // ignored devices by device path
+	notify  int                  // number of listeners, paused if 0
+	last    time.Time            // time of the last input event
+	active  chan struct{}        // closed on the next input event while notifying
+	hotplug *dirWatcher          // watches dir for added devices
+	closed  bool
 }
 
-func handleDevice(inputDevice *inputDev, activity chan struct{}) {
-	dev, err := evdev.Open(inputDevice.devPath)
+// NewInputManager opens the input devices found in dir. The rules decide
+// how the events of each device are treated and the filter which events
+// count as activity. Devices which fail to open are skipped, such that a
+// single broken device doesn't prevent monitoring the others.
+func NewInputManager(dir string, rules []InputRule, filter ActivityFilter) (*InputManager, error) {
+	m := newInputManager(dir)
+	m.rules = rules
+	m.filter = filter
+
+	devNames, err := ioutil.ReadDir(dir)
 	if err != nil {
-		inputDevice.errors <- err
+		return nil, err
+	}
+
+	hotplug, err := watchDir(dir)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Unable to watch %s for new input devices: %s", dir, err))
+	} else {
+		m.hotplug = hotplug
+		go hotplug.run(m.handleHotplug)
+	}
+
+	// loop through all event devices and check if they are keyboard/mouse like
+	for _, d := range devNames {
+		if isEventDevice(d.Name()) {
+			m.open(path.Join(dir, d.Name()), false)
+		}
+	}
+
+	return m, nil
+}
+
+func newInputManager(dir string) *InputManager {
+	return &InputManager{
+		dir:     dir,
+		devs:    make(map[string]*inputDev),
+		ignored: make(map[string]*inputDev),
+		last:    time.Now(),
+		active:  make(chan struct{}),
+	}
+}
+
+// isEventDevice returns true if name is the name of an event device node.
+func isEventDevice(name string) bool {
+	return strings.HasPrefix(name, "event")
+}
+
+// open opens and classifies the device at devPath unless already done.
+// Ignored devices are closed right away and not classified again.
+func (m *InputManager) open(devPath string, hotplug bool) {
+	m.mu.Lock()
+	_, known := m.devs[devPath]
+	_, ignored := m.ignored[devPath]
+	known = known || ignored
+	m.mu.Unlock()
+	if known {
 		return
 	}
-	defer dev.Close()
 
-	for {
-		select {
-		case evt := <-dev.Inbox:
-			if evt.Type != evKeys && evt.Type != evRel {
-				continue // not the event we are looking for
-			}
-			// the user is still alive
-			activity <- struct{}{}
-		case <-inputDevice.stop:
-			return
+	f, err := os.Open(devPath)
+	if err != nil {
+		// a hotplugged device node may not be accessible until udev
+		// has set its permissions, it is opened again when its
+		// attributes change.
+		if !hotplug {
+			slog.Error(fmt.Sprintf("Unable to open input device %s: %s", devPath, err))
 		}
+		return
 	}
+
+	d := classifyInput(f, devPath, m.rules)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.devs[devPath]; ok || m.closed {
+		f.Close()
+		return
+	}
+
+	// ignored devices are not kept open.
+	if d.action == InputIgnore {
+		m.ignored[devPath] = d
+		f.Close()
+		return
+	}
+
+	d.file = f
+	m.devs[devPath] = d
+	if hotplug {
+		slog.Info(fmt.Sprintf("Input device added: %s (%s, %s)", d.name, d.class, devPath))
+	} else {
+		slog.Info(fmt.Sprintf("Using input device: %s (%s, %s)", d.name, d.class, devPath))
+	}
+
+	go m.handleDevice(d)
 }
 
-// GetInputDevices return a InputDevs containing valid input devices.
-func GetInputDevices(errors chan error) (*InputDevs, error) {
-	devices := &InputDevs{
-		make(map[string]*inputDev),
-		make(chan struct{}),
-		errors,
+// classifyInput classifies the open input device at devPath and decides
+// how its events are treated.
+func classifyInput(f *os.File, devPath string, rules []InputRule) *inputDev {
+	// devices which don't report their capabilities, e.g. regular
+	// files, are not used for input. The identity is left empty for
+	// devices which don't report it.
+	class := classOther
+	caps, err := readInputCaps(f)
+	if err == nil {
+		class = caps.class()
 	}
 
-	devNames, err := ioutil.ReadDir(deviceDir)
+	id, _ := readInputID(f)
+	d := &inputDev{
+		name:    readInputString(f, eviocgname),
+		devPath: devPath,
+		phys:    readInputString(f, eviocgphys),
+		vendor:  id.vendor,
+		product: id.product,
+		class:   class,
+	}
+	d.action = inputAction(rules, d)
+
+	return d
+}
+
+// probeInputDevices classifies the input devices in dir without keeping
+// them open. The devices are ordered by device path.
+func probeInputDevices(dir string, rules []InputRule) ([]*inputDev, error) {
+	devNames, err := ioutil.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
 
-	// loop through all event devices and check if they are keyboard/mouse like
-	for _, d := range devNames {
-		if len(d.Name()) >= 5 && d.Name()[:5] == "event" {
-			devicePath := deviceDir + d.Name()
-			dev, err := evdev.Open(devicePath)
-			if err != nil {
-				return nil, err
-			}
+	var devs []*inputDev
+	for _, name := range devNames {
+		if !isEventDevice(name.Name()) {
+			continue
+		}
+
+		devPath := path.Join(dir, name.Name())
+		f, err := os.Open(devPath)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Unable to open input device %s: %s", devPath, err))
+			continue
+		}
+
+		devs = append(devs, classifyInput(f, devPath, rules))
+		f.Close()
+	}
 
-			name, isInput := checkDevice(dev)
-			if isInput {
-				// don't add the same device twice
-				_, ok := devices.devs[name]
-				if ok {
-					dev.Close()
-					continue
-				}
-
-				devices.devs[name] = &inputDev{
-					devicePath,
-					make(chan struct{}, 1),
-					errors,
-				}
+	return devs, nil
+}
+
+// handleDevice reports activity for every input event of the device until
+// the device is removed or closed. Reads of the device node are
+// interrupted by closing it.
+func (m *InputManager) handleDevice(d *inputDev) {
+	buf := make([]byte, inputEventSize*64)
+	for {
+		n, err := d.file.Read(buf)
+		if err != nil {
+			break
+		}
+
+		for off := 0; off+inputEventSize <= n; off += inputEventSize {
+			evt := parseInputEvent(buf[off:])
+			if !m.filter.accepts(&d.motion, d.class, time.Now(), evt) {
+				continue // not the event we are looking for
 			}
-			// close the device, since we are not gonna use it.
-			dev.Close()
+			// the user is still alive
+			m.activity(d.action == InputWakeOnly)
 		}
 	}
-	return devices, nil
+
+	m.detach(d)
+}
+
+// activity records an input event and notifies listeners. Events of
+// wake-only devices only notify listeners.
+func (m *InputManager) activity(wakeOnly bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !wakeOnly {
+		m.last = time.Now()
+	}
+
+	if m.notify > 0 {
+		close(m.active)
+		m.active = make(chan struct{})
+	}
 }
 
-// Wait monitor and wait for input events and shut down on event.
-func (devices *InputDevs) Wait(heartbeat chan struct{}) {
-	if len(devices.devs) == 0 {
-		devices.errors <- fmt.Errorf("no devices available")
+// handleHotplug opens event devices added to the device dir. Removed
+// devices are detached by handleDevice.
+func (m *InputManager) handleHotplug(name string, added bool) {
+	if !isEventDevice(name) {
 		return
 	}
 
-	for _, device := range devices.devs {
-		go handleDevice(device, devices.Activity)
+	devPath := path.Join(m.dir, name)
+	if !added {
+		m.mu.Lock()
+		delete(m.ignored, devPath)
+		m.mu.Unlock()
+		return
 	}
 
-	<-devices.Activity // wait for some activity
-	// fmt.Printf("Got activity!\n")
+	m.open(devPath, true)
+}
+
+// detach closes a removed device.
+func (m *InputManager) detach(d *inputDev) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	// stop all input device listeners
-	for _, device := range devices.devs {
-		device.stop <- struct{}{}
+	// the devices are closed by Close.
+	if m.closed {
+		return
+	}
+
+	d.file.Close()
+	if m.devs[d.devPath] == d {
+		slog.Info(fmt.Sprintf("Input device removed: %s (%s)", d.name, d.devPath))
+		delete(m.devs, d.devPath)
 	}
+}
+
+// Notify starts reporting activity. Every call must be paired with a call
+// to Pause.
+func (m *InputManager) Notify() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notify++
+}
 
-	heartbeat <- struct{}{} // send heartbeat to listener
+// Pause stops reporting activity once all listeners have paused.
+func (m *InputManager) Pause() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notify--
 }
 
-func checkDevice(dev *evdev.Device) (string, bool) {
-	if !correctDevice(dev) {
-		return "", false
+// Wait waits for the next input event.
+func (m *InputManager) Wait(ctx context.Context) error {
+	m.Notify()
+	defer m.Pause()
+
+	m.mu.Lock()
+	active := m.active
+	m.mu.Unlock()
+
+	select {
+	case <-active:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	return dev.Name(), true
+// LastActivity returns the time of the last input event.
+func (m *InputManager) LastActivity() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
 }
 
-// check if device is a keyboard, mouse or touchpad.
-func correctDevice(dev *evdev.Device) bool {
-	// check if device is a keyboard
-	if dev.Test(dev.EventTypes(), evdev.EvSync, evdev.EvKeys, evdev.EvMisc, evdev.EvLed, evdev.EvRepeat) {
-		return true
+// devices returns the open input devices ordered by device path, followed
+// by the ignored devices if all is set.
+func (m *InputManager) devices(all bool) []*inputDev {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	devs := make([]*inputDev, 0, len(m.devs))
+	for _, d := range m.devs {
+		devs = append(devs, d)
 	}
 
-	// check if device is a mouse
-	if dev.Test(dev.EventTypes(), evdev.EvSync, evdev.EvKeys, evdev.EvRelative, evdev.EvMisc) {
-		return true
+	if all {
+		for _, d := range m.ignored {
+			devs = append(devs, d)
+		}
+	}
+
+	sort.Slice(devs, func(i, j int) bool {
+		return devs[i].devPath < devs[j].devPath
+	})
+
+	return devs
+}
+
+// Close closes the input devices and stops watching for new devices.
+func (m *InputManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	if m.hotplug != nil {
+		m.hotplug.Close()
 	}
 
-	// check if device is a touchpad
-	if dev.Test(dev.EventTypes(), evdev.EvSync, evdev.EvKeys, evdev.EvAbsolute) {
-		return true
+	for _, d := range m.devs {
+		d.file.Close()
 	}
 
-	return false
+	return nil
 }
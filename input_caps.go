@@ -0,0 +1,269 @@
+package lis
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// event codes and input properties from <linux/input-event-codes.h> used to
+// classify input devices.
+const (
+	keyEsc         = 0x01
+	keyS           = 0x1f
+	btnMouse       = 0x110 // BTN_LEFT
+	btnJoystick    = 0x120 // BTN_TRIGGER
+	btnGamepad     = 0x130 // BTN_SOUTH
+	btnToolPen     = 0x140
+	btnToolFinger  = 0x145
+	btnTouch       = 0x14a
+	btnStylus      = 0x14b
+	keyMax         = 0x2ff
+	relX           = 0x00
+	relY           = 0x01
+	relMax         = 0x0f
+	absX           = 0x00
+	absY           = 0x01
+	absRX          = 0x03
+	absThrottle    = 0x06
+	absHat0X       = 0x10
+	absHat3Y       = 0x17
+	absMTPositionX = 0x35
+	absMTPositionY = 0x36
+	absMax         = 0x3f
+	evMax          = 0x1f
+
+	inputPropDirect        = 0x01 // touchscreens and tablets
+	inputPropButtonpad     = 0x02
+	inputPropPointingStick = 0x05
+	inputPropAccelerometer = 0x06
+	inputPropMax           = 0x1f
+)
+
+// inputCaps holds the capability bitmaps of an input device.
+type inputCaps struct {
+	events bitmap
+	keys   bitmap
+	rel    bitmap
+	abs    bitmap
+	props  bitmap
+}
+
+// bitmap is a bitmap as returned by the evdev ioctls.
+type bitmap []byte
+
+// newBitmap returns a bitmap large enough for the bits up to max.
+func newBitmap(max int) bitmap {
+	return make(bitmap, max/8+1)
+}
+
+// has returns true if bit n is set.
+func (b bitmap) has(n int) bool {
+	return n/8 < len(b) && b[n/8]&(1<<(n%8)) != 0
+}
+
+// set sets bit n.
+func (b bitmap) set(n int) {
+	b[n/8] |= 1 << (n % 8)
+}
+
+// any returns true if any bit in the range [from, to] is set.
+func (b bitmap) any(from, to int) bool {
+	for n := from; n <= to; n++ {
+		if b.has(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// all returns true if all bits in the range [from, to] are set.
+func (b bitmap) all(from, to int) bool {
+	for n := from; n <= to; n++ {
+		if !b.has(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// ioctl request numbers of the evdev ioctls reading from the device,
+// encoded as _IOC(_IOC_READ, 'E', nr, size).
+func eviocRead(nr, size int) uintptr {
+	return 2<<30 | uintptr(size)<<16 | 'E'<<8 | uintptr(nr)
+}
+
+func eviocgid() uintptr {
+	return eviocRead(0x02, int(unsafe.Sizeof(inputID{})))
+}
+
+func eviocgname(size int) uintptr {
+	return eviocRead(0x06, size)
+}
+
+func eviocgphys(size int) uintptr {
+	return eviocRead(0x07, size)
+}
+
+func eviocgprop(size int) uintptr {
+	return eviocRead(0x09, size)
+}
+
+func eviocgbit(ev, size int) uintptr {
+	return eviocRead(0x20+ev, size)
+}
+
+// inputIoctl runs an ioctl on an input device. The fd is not taken from
+// the file directly, as that would switch it to blocking mode and closing
+// the file would no longer interrupt reads.
+func inputIoctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	})
+	if err != nil {
+		return err
+	}
+
+	if errno != 0 {
+		return os.NewSyscallError("ioctl", errno)
+	}
+
+	return nil
+}
+
+// inputID is struct input_id.
+type inputID struct {
+	bustype uint16
+	vendor  uint16
+	product uint16
+	version uint16
+}
+
+// readInputID reads the identity of an input device.
+func readInputID(f *os.File) (inputID, error) {
+	var id inputID
+	err := inputIoctl(f, eviocgid(), unsafe.Pointer(&id))
+	return id, err
+}
+
+// readInputString reads a string like the name or physical path of an
+// input device. An empty string is returned if the device doesn't report
+// it.
+func readInputString(f *os.File, req func(size int) uintptr) string {
+	buf := make([]byte, 256)
+	err := inputIoctl(f, req(len(buf)), unsafe.Pointer(&buf[0]))
+	if err != nil {
+		return ""
+	}
+
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+
+	return string(buf)
+}
+
+// readInputCaps reads the supported event types, keys, axes and input
+// properties of an input device.
+func readInputCaps(f *os.File) (inputCaps, error) {
+	caps := inputCaps{
+		events: newBitmap(evMax),
+		keys:   newBitmap(keyMax),
+		rel:    newBitmap(relMax),
+		abs:    newBitmap(absMax),
+		props:  newBitmap(inputPropMax),
+	}
+
+	for _, q := range []struct {
+		req uintptr
+		buf bitmap
+	}{
+		{eviocgbit(0, len(caps.events)), caps.events},
+		{eviocgbit(int(evKeys), len(caps.keys)), caps.keys},
+		{eviocgbit(int(evRel), len(caps.rel)), caps.rel},
+		{eviocgbit(int(evAbs), len(caps.abs)), caps.abs},
+		{eviocgprop(len(caps.props)), caps.props},
+	} {
+		err := inputIoctl(f, q.req, unsafe.Pointer(&q.buf[0]))
+		if err != nil {
+			return inputCaps{}, err
+		}
+	}
+
+	return caps, nil
+}
+
+// class classifies the device by its capabilities, similar to the
+// input_id builtin of udev. Event types like EV_MSC and EV_SW are not
+// considered, they are reported by keyboards and touchpads alike.
+func (c inputCaps) class() string {
+	if c.props.has(inputPropAccelerometer) {
+		return classOther
+	}
+
+	if c.events.has(int(evAbs)) {
+		if class := c.absClass(); class != "" {
+			return class
+		}
+	}
+
+	if c.events.has(int(evRel)) && c.rel.has(relX) && c.rel.has(relY) && c.keys.has(btnMouse) {
+		if c.props.has(inputPropPointingStick) {
+			return classTrackpoint
+		}
+		return classMouse
+	}
+
+	if !c.events.has(int(evKeys)) {
+		return classOther
+	}
+
+	// keyboards have at least the keys from KEY_ESC to KEY_S, other
+	// switches and sensors may report a few keys like KEY_POWER.
+	if c.keys.all(keyEsc, keyS) {
+		return classKeyboard
+	}
+
+	if c.keys.any(btnJoystick, btnGamepad+0xf) {
+		return classGamepad
+	}
+
+	return classOther
+}
+
+// absClass classifies devices with absolute axes, or returns an empty
+// string if the axes don't identify the device.
+func (c inputCaps) absClass() string {
+	hasXY := c.abs.has(absX) && c.abs.has(absY)
+	hasMT := c.abs.has(absMTPositionX) && c.abs.has(absMTPositionY)
+	if !hasXY && !hasMT {
+		if c.abs.has(absRX) || c.abs.has(absThrottle) {
+			return classGamepad
+		}
+		return ""
+	}
+
+	switch {
+	case c.keys.has(btnStylus) || c.keys.has(btnToolPen):
+		return classPen
+	case (c.keys.has(btnToolFinger) || c.props.has(inputPropButtonpad)) && !c.props.has(inputPropDirect):
+		// touchscreens may report fingers as well.
+		return classTouchpad
+	case c.keys.has(btnMouse):
+		// absolute pointers e.g. the tablet of a virtual machine.
+		return classMouse
+	case c.keys.has(btnTouch) || c.props.has(inputPropDirect):
+		return classTouchscreen
+	case c.keys.any(btnJoystick, btnGamepad+0xf):
+		return classGamepad
+	}
+
+	return ""
+}
@@ -0,0 +1,198 @@
+package lis
+
+import (
+	"testing"
+)
+
+// testCaps returns the capabilities of a device supporting the given event
+// types, keys, relative and absolute axes and properties.
+func testCaps(events, keys, rel, abs, props []int) inputCaps {
+	caps := inputCaps{
+		events: newBitmap(evMax),
+		keys:   newBitmap(keyMax),
+		rel:    newBitmap(relMax),
+		abs:    newBitmap(absMax),
+		props:  newBitmap(inputPropMax),
+	}
+
+	for _, b := range []struct {
+		bitmap bitmap
+		bits   []int
+	}{
+		{caps.events, events},
+		{caps.keys, keys},
+		{caps.rel, rel},
+		{caps.abs, abs},
+		{caps.props, props},
+	} {
+		for _, n := range b.bits {
+			b.bitmap.set(n)
+		}
+	}
+
+	return caps
+}
+
+func TestInputCapsClass(t *testing.T) {
+	const (
+		evSync = 0x00
+		evMsc  = 0x04
+		evSw   = 0x05
+		evLed  = 0x11
+		evRep  = 0x14
+
+		keyPower      = 0x74
+		btnRight      = 0x111
+		btnToolRubber = 0x141
+		absPressure   = 0x18
+
+		inputPropPointer = 0x00
+	)
+
+	keyboardKeys := []int{}
+	for n := keyEsc; n <= 0x58; n++ {
+		keyboardKeys = append(keyboardKeys, n)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		caps  inputCaps
+		class string
+	}{
+		{
+			name: "keyboard",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), evMsc, evLed, evRep},
+				keyboardKeys, nil, nil, nil),
+			class: classKeyboard,
+		},
+		{
+			name: "keyboard without leds",
+			caps: testCaps(
+				[]int{evSync, int(evKeys)},
+				keyboardKeys, nil, nil, nil),
+			class: classKeyboard,
+		},
+		{
+			name: "power button",
+			caps: testCaps(
+				[]int{evSync, int(evKeys)},
+				[]int{keyPower}, nil, nil, nil),
+			class: classOther,
+		},
+		{
+			name: "lid switch",
+			caps: testCaps(
+				[]int{evSync, evSw},
+				nil, nil, nil, nil),
+			class: classOther,
+		},
+		{
+			name: "mouse",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), int(evRel), evMsc},
+				[]int{btnMouse, btnRight}, []int{relX, relY}, nil, nil),
+			class: classMouse,
+		},
+		{
+			name: "trackpoint",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), int(evRel)},
+				[]int{btnMouse, btnRight}, []int{relX, relY}, nil,
+				[]int{inputPropPointer, inputPropPointingStick}),
+			class: classTrackpoint,
+		},
+		{
+			name: "touchpad",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), int(evAbs)},
+				[]int{btnMouse, btnToolFinger, btnTouch},
+				nil, []int{absX, absY, absMTPositionX, absMTPositionY},
+				[]int{inputPropPointer, inputPropButtonpad}),
+			class: classTouchpad,
+		},
+		{
+			name: "touchpad with misc and switch events",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), int(evAbs), evMsc, evSw},
+				[]int{btnMouse, btnToolFinger, btnTouch},
+				nil, []int{absX, absY, absMTPositionX, absMTPositionY},
+				[]int{inputPropPointer}),
+			class: classTouchpad,
+		},
+		{
+			name: "touchscreen",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), int(evAbs)},
+				[]int{btnTouch},
+				nil, []int{absX, absY, absMTPositionX, absMTPositionY},
+				[]int{inputPropDirect}),
+			class: classTouchscreen,
+		},
+		{
+			name: "touchscreen reporting fingers",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), int(evAbs)},
+				[]int{btnTouch, btnToolFinger},
+				nil, []int{absX, absY, absMTPositionX, absMTPositionY},
+				[]int{inputPropDirect}),
+			class: classTouchscreen,
+		},
+		{
+			name: "multitouch only touchscreen",
+			caps: testCaps(
+				[]int{evSync, int(evAbs)},
+				nil, nil, []int{absMTPositionX, absMTPositionY},
+				[]int{inputPropDirect}),
+			class: classTouchscreen,
+		},
+		{
+			name: "pen",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), int(evAbs), evMsc},
+				[]int{btnToolPen, btnToolRubber, btnTouch, btnStylus},
+				nil, []int{absX, absY, absPressure},
+				[]int{inputPropDirect}),
+			class: classPen,
+		},
+		{
+			name: "virtual machine tablet",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), int(evAbs)},
+				[]int{btnMouse, btnRight},
+				nil, []int{absX, absY}, nil),
+			class: classMouse,
+		},
+		{
+			name: "gamepad",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), int(evAbs)},
+				[]int{btnGamepad, btnGamepad + 1},
+				nil, []int{absX, absY, absRX, absHat0X}, nil),
+			class: classGamepad,
+		},
+		{
+			name: "joystick",
+			caps: testCaps(
+				[]int{evSync, int(evKeys), int(evAbs)},
+				[]int{btnJoystick},
+				nil, []int{absX, absY, absThrottle}, nil),
+			class: classGamepad,
+		},
+		{
+			name: "accelerometer",
+			caps: testCaps(
+				[]int{evSync, int(evAbs)},
+				nil, nil, []int{absX, absY},
+				[]int{inputPropAccelerometer}),
+			class: classOther,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			class := tc.caps.class()
+			if class != tc.class {
+				t.Errorf("expected class %s, got %s", tc.class, class)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package lis
+
+import (
+	"time"
+)
+
+// defaultMotionWindow is the default period relative motion is accumulated
+// over.
+const defaultMotionWindow = time.Second
+
+// ActivityFilter decides which input events count as user activity, such
+// that sensor noise like a mouse on a vibrating desk or a drifting
+// trackpoint doesn't wake lis.
+type ActivityFilter struct {
+	Motion   int           // minimum motion within Window, any motion counts if 0
+	Window   time.Duration // period motion is accumulated over
+	KeyPress bool          // only key presses count, not key releases and autorepeat
+	Debounce time.Duration // motion only counts once it persists for Debounce
+}
+
+// motionState is the motion of a device accumulated within the current
+// window.
+type motionState struct {
+	motion int
+	start  time.Time
+	run    time.Time        // start of the current run of motion events
+	prev   time.Time        // time of the previous motion event
+	axes   map[uint16]int32 // last values of the absolute axes of gamepads
+}
+
+// accepts returns true if the event counts as activity. Relative motion and
+// the changes of the stick and trigger axes of gamepads are accumulated in
+// s until they reach the minimum motion within the window and have
+// persisted for the debounce period. Keys count right away.
+func (f ActivityFilter) accepts(s *motionState, class string, now time.Time, evt inputEvent) bool {
+	switch evt.Type {
+	case evKeys:
+		// key events have the value 0 on release and 2 on autorepeat.
+		return !f.KeyPress || evt.Value == 1
+	case evRel:
+		// scroll wheels and other relative axes are not noisy.
+		if !f.filtersMotion() || (evt.Code != relX && evt.Code != relY) {
+			return true
+		}
+
+		return f.move(s, now, int(evt.Value))
+	case evAbs:
+		// touch and pen positions are not noisy, neither are the
+		// digital hats of gamepads.
+		if !f.filtersMotion() || class != classGamepad || (evt.Code >= absHat0X && evt.Code <= absHat3Y) {
+			return true
+		}
+
+		if s.axes == nil {
+			s.axes = make(map[uint16]int32)
+		}
+
+		// the first value of an axis is only the reference for the
+		// following changes.
+		prev, ok := s.axes[evt.Code]
+		s.axes[evt.Code] = evt.Value
+		if !ok {
+			return false
+		}
+
+		return f.move(s, now, int(evt.Value)-int(prev))
+	}
+
+	return false
+}
+
+// filtersMotion returns true if motion has to reach a minimum or persist
+// before counting as activity.
+func (f ActivityFilter) filtersMotion() bool {
+	return f.Motion > 0 || f.Debounce > 0
+}
+
+// move accumulates motion in s and returns true once it reaches the
+// minimum motion within the window and has persisted for the debounce
+// period. A gap between motion events longer than the debounce period
+// starts a new run.
+func (f ActivityFilter) move(s *motionState, now time.Time, motion int) bool {
+	if now.Sub(s.prev) > f.Debounce {
+		s.run = now
+	}
+	s.prev = now
+
+	if now.Sub(s.start) > f.Window {
+		s.motion = 0
+		s.start = now
+	}
+
+	if motion < 0 {
+		motion = -motion
+	}
+	s.motion += motion
+
+	if s.motion < f.Motion || now.Sub(s.run) < f.Debounce {
+		return false
+	}
+
+	s.motion = 0
+	s.start = now
+	return true
+}
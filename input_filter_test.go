@@ -0,0 +1,146 @@
+package lis
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	evMsc    = 0x04
+	relWheel = 0x08
+)
+
+func TestActivityFilter(t *testing.T) {
+	start := time.Now()
+	filter := ActivityFilter{Motion: 10, Window: time.Second, KeyPress: true}
+	var s motionState
+
+	for _, tc := range []struct {
+		name    string
+		after   time.Duration // time of the event since start
+		evt     inputEvent
+		accepts bool
+	}{
+		{"key press", 0, inputEvent{Type: evKeys, Code: keyEsc, Value: 1}, true},
+		{"key autorepeat", 0, inputEvent{Type: evKeys, Code: keyEsc, Value: 2}, false},
+		{"key release", 0, inputEvent{Type: evKeys, Code: keyEsc, Value: 0}, false},
+		{"misc event", 0, inputEvent{Type: evMsc, Value: 4}, false},
+		{"small motion", 0, inputEvent{Type: evRel, Code: relX, Value: 4}, false},
+		{"accumulated motion", 100 * time.Millisecond, inputEvent{Type: evRel, Code: relY, Value: -6}, true},
+		{"motion after activity", 200 * time.Millisecond, inputEvent{Type: evRel, Code: relX, Value: 6}, false},
+		{"motion in next window", 1500 * time.Millisecond, inputEvent{Type: evRel, Code: relX, Value: 6}, false},
+		{"accumulated motion in next window", 1600 * time.Millisecond, inputEvent{Type: evRel, Code: relX, Value: 6}, true},
+		{"scroll", 1700 * time.Millisecond, inputEvent{Type: evRel, Code: relWheel, Value: 1}, true},
+		{"touch", 1800 * time.Millisecond, inputEvent{Type: evAbs, Code: absX, Value: 100}, true},
+	} {
+		if accepts := filter.accepts(&s, classMouse, start.Add(tc.after), tc.evt); accepts != tc.accepts {
+			t.Errorf("%s: expected accepts %t, got %t", tc.name, tc.accepts, accepts)
+		}
+	}
+
+	// any motion counts without a minimum motion.
+	filter = ActivityFilter{Window: time.Second}
+	if !filter.accepts(&s, classMouse, start, inputEvent{Type: evRel, Code: relX, Value: 1}) {
+		t.Errorf("expected motion to be accepted without minimum")
+	}
+
+	if !filter.accepts(&s, classKeyboard, start, inputEvent{Type: evKeys, Code: keyEsc, Value: 0}) {
+		t.Errorf("expected key release to be accepted")
+	}
+}
+
+func TestActivityFilterGamepad(t *testing.T) {
+	start := time.Now()
+	filter := ActivityFilter{Motion: 100, Window: time.Second}
+	var s motionState
+
+	for _, tc := range []struct {
+		name    string
+		after   time.Duration // time of the event since start
+		evt     inputEvent
+		accepts bool
+	}{
+		{"first stick value", 0, inputEvent{Type: evAbs, Code: absX, Value: 10}, false},
+		{"stick drift", 100 * time.Millisecond, inputEvent{Type: evAbs, Code: absX, Value: -20}, false},
+		{"drift of other axis", 200 * time.Millisecond, inputEvent{Type: evAbs, Code: absY, Value: 30}, false},
+		{"accumulated drift in next window", 1500 * time.Millisecond, inputEvent{Type: evAbs, Code: absX, Value: 10}, false},
+		{"stick moved", 1600 * time.Millisecond, inputEvent{Type: evAbs, Code: absX, Value: 5000}, true},
+		{"hat", 1700 * time.Millisecond, inputEvent{Type: evAbs, Code: absHat0X, Value: 1}, true},
+		{"button", 1800 * time.Millisecond, inputEvent{Type: evKeys, Code: btnGamepad, Value: 1}, true},
+	} {
+		if accepts := filter.accepts(&s, classGamepad, start.Add(tc.after), tc.evt); accepts != tc.accepts {
+			t.Errorf("%s: expected accepts %t, got %t", tc.name, tc.accepts, accepts)
+		}
+	}
+
+	// absolute axes of other devices are not filtered.
+	s = motionState{}
+	if !filter.accepts(&s, classTouchpad, start, inputEvent{Type: evAbs, Code: absX, Value: 1}) {
+		t.Errorf("expected touchpad motion to be accepted")
+	}
+}
+
+func TestActivityFilterDebounce(t *testing.T) {
+	start := time.Now()
+	filter := ActivityFilter{Window: time.Second, KeyPress: true, Debounce: 100 * time.Millisecond}
+	var s motionState
+
+	for _, tc := range []struct {
+		name    string
+		after   time.Duration // time of the event since start
+		evt     inputEvent
+		accepts bool
+	}{
+		{"key press", 0, inputEvent{Type: evKeys, Code: keyEsc, Value: 1}, true},
+		{"key release", 0, inputEvent{Type: evKeys, Code: keyEsc, Value: 0}, false},
+		{"single motion", 0, inputEvent{Type: evRel, Code: relX, Value: 50}, false},
+		{"motion after a gap", 500 * time.Millisecond, inputEvent{Type: evRel, Code: relX, Value: 50}, false},
+		{"key press within motion", 520 * time.Millisecond, inputEvent{Type: evKeys, Code: keyS, Value: 1}, true},
+		{"motion within debounce", 560 * time.Millisecond, inputEvent{Type: evRel, Code: relY, Value: 50}, false},
+		{"persisting motion", 620 * time.Millisecond, inputEvent{Type: evRel, Code: relX, Value: 50}, true},
+		{"continued motion", 640 * time.Millisecond, inputEvent{Type: evRel, Code: relX, Value: 50}, true},
+		{"motion after the next gap", time.Second, inputEvent{Type: evRel, Code: relX, Value: 50}, false},
+		{"scroll", time.Second, inputEvent{Type: evRel, Code: relWheel, Value: 1}, true},
+		{"key press after a gap", 5 * time.Second, inputEvent{Type: evKeys, Code: keyEsc, Value: 1}, true},
+	} {
+		if accepts := filter.accepts(&s, classMouse, start.Add(tc.after), tc.evt); accepts != tc.accepts {
+			t.Errorf("%s: expected accepts %t, got %t", tc.name, tc.accepts, accepts)
+		}
+	}
+}
+
+func TestInputManagerKeyPressDebounce(t *testing.T) {
+	m := newInputManager(t.TempDir())
+	m.filter = ActivityFilter{Window: time.Second, KeyPress: true, Debounce: 100 * time.Millisecond}
+	defer m.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %s", err)
+	}
+	defer w.Close()
+
+	d := &inputDev{devPath: "event0", class: classKeyboard, action: InputActivity, file: r}
+	m.devs[d.devPath] = d
+	go m.handleDevice(d)
+
+	// key presses further apart than the debounce period count right
+	// away.
+	for i := 0; i < 2; i++ {
+		last := m.LastActivity()
+
+		_, err = w.Write(keyPressEvent())
+		if err != nil {
+			t.Fatalf("failed to write event: %s", err)
+		}
+
+		for start := time.Now(); !m.LastActivity().After(last); time.Sleep(time.Millisecond) {
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("key press %d was not counted as activity", i)
+			}
+		}
+
+		time.Sleep(2 * m.filter.Debounce)
+	}
+}
@@ -0,0 +1,70 @@
+package lis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"syscall"
+)
+
+// dirWatcher reports files added to or removed from a dir through inotify.
+type dirWatcher struct {
+	file *os.File
+}
+
+// watchDir starts watching dir.
+func watchDir(dir string) (*dirWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	// IN_ATTRIB reports device nodes becoming accessible after udev has
+	// set their permissions.
+	_, err = syscall.InotifyAddWatch(fd, dir, syscall.IN_CREATE|syscall.IN_ATTRIB|syscall.IN_DELETE)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	// the non-blocking fd is handled by the runtime poller, such that
+	// Close interrupts a blocked read.
+	return &dirWatcher{file: os.NewFile(uintptr(fd), dir)}, nil
+}
+
+// run calls fn with the name of every file added to or removed from the
+// dir until the watcher is closed. Files are reported as added again when
+// their attributes change.
+func (w *dirWatcher) run(fn func(name string, added bool)) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			length := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			off += syscall.SizeofInotifyEvent
+
+			if off+length > n {
+				break
+			}
+
+			name := string(bytes.TrimRight(buf[off:off+length], "\x00"))
+			off += length
+
+			if name == "" || mask&syscall.IN_ISDIR != 0 {
+				continue
+			}
+
+			fn(name, mask&syscall.IN_DELETE == 0)
+		}
+	}
+}
+
+// Close stops watching the dir.
+func (w *dirWatcher) Close() error {
+	return w.file.Close()
+}
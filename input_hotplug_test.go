@@ -0,0 +1,70 @@
+package lis
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestDirWatcher(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := watchDir(dir)
+	if err != nil {
+		t.Fatalf("failed to watch dir: %s", err)
+	}
+
+	type change struct {
+		name  string
+		added bool
+	}
+
+	changes := make(chan change, 10)
+	done := make(chan struct{})
+	go func() {
+		w.run(func(name string, added bool) {
+			changes <- change{name, added}
+		})
+		close(done)
+	}()
+
+	expect := func(expected change) {
+		t.Helper()
+		select {
+		case c := <-changes:
+			if c != expected {
+				t.Errorf("expected %+v, got %+v", expected, c)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %+v", expected)
+		}
+	}
+
+	devPath := path.Join(dir, "event3")
+	err = os.WriteFile(devPath, nil, 0600)
+	if err != nil {
+		t.Fatalf("failed to create device: %s", err)
+	}
+	expect(change{"event3", true})
+
+	// udev setting permissions is reported as added again.
+	err = os.Chmod(devPath, 0660)
+	if err != nil {
+		t.Fatalf("failed to change permissions: %s", err)
+	}
+	expect(change{"event3", true})
+
+	err = os.Remove(devPath)
+	if err != nil {
+		t.Fatalf("failed to remove device: %s", err)
+	}
+	expect(change{"event3", false})
+
+	w.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watcher was not stopped by Close")
+	}
+}
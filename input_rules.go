@@ -0,0 +1,106 @@
+package lis
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// InputIgnore ignores the events of the device.
+	InputIgnore = "ignore"
+	// InputWakeOnly wakes lis from the idle stages on events of the
+	// device, but the events don't postpone idle.
+	InputWakeOnly = "wake-only"
+	// InputActivity counts the events of the device as user activity.
+	InputActivity = "activity"
+)
+
+var inputActions = []string{InputIgnore, InputWakeOnly, InputActivity}
+
+const (
+	classKeyboard    = "keyboard"
+	classMouse       = "mouse"
+	classTouchpad    = "touchpad"
+	classTouchscreen = "touchscreen"
+	classPen         = "pen" // pens and graphics tablets
+	classGamepad     = "gamepad"
+	classTrackpoint  = "trackpoint"
+	// classOther is the class of devices which are not used for input by
+	// the user, e.g. switches and sensors.
+	classOther = "other"
+)
+
+var inputClasses = []string{
+	classKeyboard, classMouse, classTouchpad, classTouchscreen,
+	classPen, classGamepad, classTrackpoint, classOther,
+}
+
+// matches returns true if the device matches all the fields given by the
+// rule.
+func (r InputRule) matches(d *inputDev) bool {
+	if r.Name != "" && !globMatch(r.Name, d.name) {
+		return false
+	}
+
+	if r.Vendor != nil && *r.Vendor != d.vendor {
+		return false
+	}
+
+	if r.Product != nil && *r.Product != d.product {
+		return false
+	}
+
+	if r.Phys != "" && !globMatch(r.Phys, d.phys) {
+		return false
+	}
+
+	return r.Class == "" || r.Class == d.class
+}
+
+// globMatch returns true if name matches pattern. '*' matches any text and
+// '?' any single character, including '/' in physical paths.
+func globMatch(pattern, name string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("^" + expr + "$").MatchString(name)
+}
+
+// inputAction returns the action of the first rule matching the device.
+// Without a matching rule the events of devices used for input are counted
+// as activity and other devices are ignored.
+func inputAction(rules []InputRule, d *inputDev) string {
+	for _, r := range rules {
+		if r.matches(d) {
+			return r.Action
+		}
+	}
+
+	if d.class == classOther {
+		return InputIgnore
+	}
+
+	return InputActivity
+}
+
+// validateInputRules checks that input rules have known actions and match
+// on at least one field.
+func validateInputRules(rules []InputRule) error {
+	for i, r := range rules {
+		if !slices.Contains(inputActions, r.Action) {
+			return fmt.Errorf("input %d: invalid action '%s', must be one of: %s", i+1, r.Action, strings.Join(inputActions, ", "))
+		}
+
+		if r.Class != "" && !slices.Contains(inputClasses, r.Class) {
+			return fmt.Errorf("input %d: invalid class '%s', must be one of: %s", i+1, r.Class, strings.Join(inputClasses, ", "))
+		}
+
+		if r.Name == "" && r.Vendor == nil && r.Product == nil && r.Phys == "" && r.Class == "" {
+			return fmt.Errorf("input %d: must match on name, vendor, product, phys or class", i+1)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package lis
+
+import (
+	"testing"
+)
+
+func TestInputAction(t *testing.T) {
+	yubikey := &inputDev{name: "Yubico YubiKey OTP+FIDO+CCID", vendor: 0x1050, product: 0x0407, phys: "usb-0000:00:14.0-1/input0", class: classKeyboard}
+	lid := &inputDev{name: "Lid Switch", phys: "PNP0C0D/button/input0", class: classOther}
+	mouse := &inputDev{name: "Logitech USB Receiver", vendor: 0x046d, product: 0xc52b, phys: "usb-0000:00:14.0-2/input1", class: classMouse}
+
+	vendor := uint16(0x1050)
+	product := uint16(0xc52b)
+	rules := []InputRule{
+		{Vendor: &vendor, Action: InputIgnore},
+		{Vendor: nil, Product: &product, Phys: "usb-*", Action: InputWakeOnly},
+		{Name: "Lid*", Action: InputActivity},
+	}
+
+	for _, tc := range []struct {
+		dev      *inputDev
+		rules    []InputRule
+		expected string
+	}{
+		{yubikey, nil, InputActivity},
+		{lid, nil, InputIgnore},
+		{mouse, nil, InputActivity},
+		{yubikey, rules, InputIgnore},
+		{lid, rules, InputActivity},
+		{mouse, rules, InputWakeOnly},
+		{mouse, []InputRule{{Class: classMouse, Name: "Logitech*", Action: InputIgnore}}, InputIgnore},
+		{mouse, []InputRule{{Class: classKeyboard, Name: "Logitech*", Action: InputIgnore}}, InputActivity},
+		{mouse, []InputRule{{Phys: "usb-0000:00:14.0-2/input0", Action: InputIgnore}}, InputActivity},
+		{mouse, []InputRule{{Name: "Logitech USB Receiver?", Action: InputIgnore}}, InputActivity},
+		{mouse, []InputRule{{Name: "Logitech (USB)*", Action: InputIgnore}}, InputActivity},
+	} {
+		action := inputAction(tc.rules, tc.dev)
+		if action != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.dev.name, tc.expected, action)
+		}
+	}
+}
+
+func TestValidateInputRules(t *testing.T) {
+	vendor := uint16(0x1050)
+	err := validateInputRules([]InputRule{
+		{Vendor: &vendor, Action: InputIgnore},
+		{Class: classTouchpad, Action: InputWakeOnly},
+	})
+	if err != nil {
+		t.Errorf("should not cause error: %s", err)
+	}
+
+	for _, rule := range []InputRule{
+		{Name: "mouse", Action: "wake"},
+		{Action: InputIgnore},
+		{Class: "joystick", Action: InputIgnore},
+	} {
+		err := validateInputRules([]InputRule{rule})
+		if err == nil {
+			t.Errorf("expected error for rule: %+v", rule)
+		}
+	}
+}
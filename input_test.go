@@ -0,0 +1,201 @@
+package lis
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestInputManager(t *testing.T) {
+	dir := t.TempDir()
+
+	// a device which fails to open doesn't prevent monitoring the others.
+	err := os.Symlink(path.Join(dir, "missing"), path.Join(dir, "event1"))
+	if err != nil {
+		t.Fatalf("failed to create device: %s", err)
+	}
+
+	// files which are not input devices are classified once.
+	err = os.WriteFile(path.Join(dir, "event0"), nil, 0600)
+	if err != nil {
+		t.Fatalf("failed to create device: %s", err)
+	}
+
+	m, err := NewInputManager(dir, nil, ActivityFilter{})
+	if err != nil {
+		t.Fatalf("failed to open input devices: %s", err)
+	}
+	defer m.Close()
+
+	if n := len(m.devices(false)); n != 0 {
+		t.Errorf("expected no input devices, got %d", n)
+	}
+
+	// ignored returns true if devPath has been classified as not being an
+	// input device.
+	ignored := func(devPath string) bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.ignored[devPath] != nil
+	}
+
+	if !ignored(path.Join(dir, "event0")) {
+		t.Errorf("expected event0 to be ignored")
+	}
+
+	// devices connected later are classified as well.
+	hotplugged := path.Join(dir, "event2")
+	err = os.WriteFile(hotplugged, nil, 0600)
+	if err != nil {
+		t.Fatalf("failed to create device: %s", err)
+	}
+
+	for start := time.Now(); !ignored(hotplugged); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("hotplugged device was not classified")
+		}
+	}
+
+	err = os.Remove(hotplugged)
+	if err != nil {
+		t.Fatalf("failed to remove device: %s", err)
+	}
+
+	for start := time.Now(); ignored(hotplugged); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("removed device was not forgotten")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// activity while paused is not reported to later listeners.
+	m.activity(false)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Wait(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Wait returned without input: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	m.activity(false)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Wait failed: %s", err)
+		}
+	case <-ctx.Done():
+		t.Fatalf("Wait did not detect input")
+	}
+
+	cctx, ccancel := context.WithCancel(context.Background())
+	ccancel()
+	if err := m.Wait(cctx); err != context.Canceled {
+		t.Errorf("expected Wait to be cancelled, got: %v", err)
+	}
+}
+
+// keyPressEvent returns a key press as struct input_event.
+func keyPressEvent() []byte {
+	evt := make([]byte, inputEventSize)
+	off := inputEventSize - 8
+	binary.NativeEndian.PutUint16(evt[off:], evKeys)
+	binary.NativeEndian.PutUint16(evt[off+2:], keyEsc)
+	binary.NativeEndian.PutUint32(evt[off+4:], 1)
+	return evt
+}
+
+func TestInputManagerHandleDevice(t *testing.T) {
+	m := newInputManager(t.TempDir())
+	defer m.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %s", err)
+	}
+	defer w.Close()
+
+	d := &inputDev{devPath: "event0", action: InputActivity, file: r}
+	m.devs[d.devPath] = d
+
+	done := make(chan struct{})
+	go func() {
+		m.handleDevice(d)
+		close(done)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- m.Wait(ctx)
+	}()
+
+	evt := keyPressEvent()
+
+	// keep writing until the waiter has been registered.
+	for written := false; !written; {
+		_, err = w.Write(evt)
+		if err != nil {
+			t.Fatalf("failed to write event: %s", err)
+		}
+
+		select {
+		case err := <-waited:
+			if err != nil {
+				t.Fatalf("Wait failed: %s", err)
+			}
+			written = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// closing the write end removes the device.
+	w.Close()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatalf("device was not detached")
+	}
+
+	if n := len(m.devices(false)); n != 0 {
+		t.Errorf("expected no input devices, got %d", n)
+	}
+}
+
+func TestInputManagerClose(t *testing.T) {
+	m := newInputManager(t.TempDir())
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %s", err)
+	}
+	defer w.Close()
+
+	d := &inputDev{devPath: "event0", action: InputActivity, file: r}
+	m.devs[d.devPath] = d
+
+	done := make(chan struct{})
+	go func() {
+		m.handleDevice(d)
+		close(done)
+	}()
+
+	// Close interrupts the pending read.
+	m.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close did not interrupt reading the device")
+	}
+}
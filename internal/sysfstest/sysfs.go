@@ -0,0 +1,129 @@
+// Package sysfstest provides a fake sysfs tree for testing code which
+// controls backlight and led devices.
+package sysfstest
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Sysfs is a fake sysfs tree in a temporary directory.
+type Sysfs struct {
+	Root string
+	t    testing.TB
+}
+
+// New creates a new empty fake sysfs tree. The tree is removed when the
+// test finishes.
+func New(t testing.TB) *Sysfs {
+	t.Helper()
+
+	return &Sysfs{
+		Root: t.TempDir(),
+		t:    t,
+	}
+}
+
+// AddBacklight adds a device to class/backlight. actual_brightness is a
+// symlink to brightness, so it reflects values written by the code under
+// test just like the kernel does.
+func (s *Sysfs) AddBacklight(name, typ string, max, value int) {
+	s.t.Helper()
+
+	dir := s.devicePath("backlight", name)
+	s.mkdir(dir)
+	s.write(path.Join(dir, "type"), typ)
+	s.write(path.Join(dir, "max_brightness"), strconv.Itoa(max))
+	s.write(path.Join(dir, "brightness"), strconv.Itoa(value))
+	s.write(path.Join(dir, "bl_power"), "0")
+
+	err := os.Symlink("brightness", path.Join(dir, "actual_brightness"))
+	if err != nil {
+		s.t.Fatalf("failed to create actual_brightness: %s", err)
+	}
+}
+
+// AddLed adds a device to class/leds.
+func (s *Sysfs) AddLed(name string, max, value int) {
+	s.t.Helper()
+
+	dir := s.devicePath("leds", name)
+	s.mkdir(dir)
+	s.write(path.Join(dir, "max_brightness"), strconv.Itoa(max))
+	s.write(path.Join(dir, "brightness"), strconv.Itoa(value))
+}
+
+// Remove removes a device from the tree.
+func (s *Sysfs) Remove(subsystem, name string) {
+	s.t.Helper()
+
+	err := os.RemoveAll(s.devicePath(subsystem, name))
+	if err != nil {
+		s.t.Fatalf("failed to remove %s/%s: %s", subsystem, name, err)
+	}
+}
+
+// Brightness reads the brightness value of a device.
+func (s *Sysfs) Brightness(subsystem, name string) int {
+	s.t.Helper()
+
+	return s.readInt(path.Join(s.devicePath(subsystem, name), "brightness"))
+}
+
+// BlPower reads the bl_power value of a backlight device.
+func (s *Sysfs) BlPower(name string) int {
+	s.t.Helper()
+
+	return s.readInt(path.Join(s.devicePath("backlight", name), "bl_power"))
+}
+
+// SetBrightness sets the brightness value of a device, as if it was
+// changed by something else than the code under test.
+func (s *Sysfs) SetBrightness(subsystem, name string, value int) {
+	s.t.Helper()
+
+	s.write(path.Join(s.devicePath(subsystem, name), "brightness"), strconv.Itoa(value))
+}
+
+// Path returns a path inside the tree.
+func (s *Sysfs) Path(elem ...string) string {
+	return path.Join(append([]string{s.Root}, elem...)...)
+}
+
+func (s *Sysfs) devicePath(subsystem, name string) string {
+	return path.Join(s.Root, "class", subsystem, name)
+}
+
+func (s *Sysfs) mkdir(dir string) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		s.t.Fatalf("failed to create %s: %s", dir, err)
+	}
+}
+
+func (s *Sysfs) readInt(fpath string) int {
+	s.t.Helper()
+
+	buf, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		s.t.Fatalf("failed to read %s: %s", fpath, err)
+	}
+
+	v, err := strconv.Atoi(strings.TrimSpace(string(buf)))
+	if err != nil {
+		s.t.Fatalf("invalid value in %s: %s", fpath, err)
+	}
+
+	return v
+}
+
+func (s *Sysfs) write(fpath, value string) {
+	err := ioutil.WriteFile(fpath, []byte(value+"\n"), 0644)
+	if err != nil {
+		s.t.Fatalf("failed to write %s: %s", fpath, err)
+	}
+}
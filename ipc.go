@@ -2,12 +2,18 @@ package lis
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log/slog"
+	"math"
 	"net"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // IPCCmdType defines the type of IPC command.
@@ -22,23 +28,101 @@ const (
 	IPCSetDown
 	// IPCStatus is the command for getting current brightness value.
 	IPCStatus
-	// IPCDPMSOn is the command for enabling DPMS.
+	// IPCDPMSOn is the command for turning the displays on.
 	IPCDPMSOn
-	// IPCDPMSOff is the command for disabling DPMS.
+	// IPCDPMSOff is the command for turning the displays off.
 	IPCDPMSOff
+	// IPCInhibit is the command for inhibiting idle.
+	IPCInhibit
+	// IPCUnInhibit is the command for releasing an inhibitor.
+	IPCUnInhibit
+	// IPCRelease releases the inhibitors of a closed connection.
+	IPCRelease
+	// IPCDevices is the command for listing the input devices.
+	IPCDevices
 )
 
+// inhibitor source of IPC clients.
+const inhibitIPC = "ipc"
+
+// max time to wait for a subscriber to receive an event.
+const broadcastTimeout = time.Second
+
+// number of events queued for a subscriber before it is dropped.
+const subscriberBuffer = 16
+
 // IPCCmd defines an IPC command.
 type IPCCmd struct {
-	typ  IPCCmdType
-	val  interface{}
-	resp chan interface{}
+	typ    IPCCmdType
+	val    interface{}
+	target *IPCTarget
+	resp   chan interface{}
+}
+
+// ipcInhibit is the value of an INHIBIT command.
+type ipcInhibit struct {
+	owner    string // connection holding the inhibitor, if not timed
+	reason   string
+	duration time.Duration // time until the inhibitor expires, 0 if held by the connection
+}
+
+// ipcStatus is the response to a STATUS command.
+type ipcStatus struct {
+	brightness float64
+	idle       idleStatus
+}
+
+// IPCTarget addresses the devices an IPC command applies to. Targets are
+// written as '<class>' or '<class>=<name>' e.g. 'panel=intel_backlight'.
+type IPCTarget struct {
+	Class string // device class, 'panel' or 'kbd'
+	Name  string // device name, empty to address all devices of the class
+}
+
+// ParseIPCTarget parses a target argument.
+func ParseIPCTarget(arg string) (*IPCTarget, error) {
+	target := &IPCTarget{Class: arg}
+	if i := strings.Index(arg, "="); i >= 0 {
+		target.Class = arg[:i]
+		target.Name = arg[i+1:]
+		if target.Name == "" {
+			return nil, fmt.Errorf("missing device name: %s", arg)
+		}
+	}
+
+	switch target.Class {
+	case classPanel, classKbd:
+	default:
+		return nil, fmt.Errorf("invalid device class: %s", target.Class)
+	}
+
+	return target, nil
+}
+
+func (t *IPCTarget) String() string {
+	if t == nil {
+		return ""
+	}
+
+	if t.Name == "" {
+		return t.Class
+	}
+
+	return t.Class + "=" + t.Name
 }
 
 type client struct {
 	net.Conn
-	ipcCh  chan<- IPCCmd
-	errors chan<- error
+	id       uint64
+	server   *IPCServer
+	ipcCh    chan<- IPCCmd
+	errors   chan<- error
+	inhibits bool // true if the connection has held inhibitors
+}
+
+// owner identifies the connection as the owner of inhibitors.
+func (c *client) owner() string {
+	return fmt.Sprintf("conn%d", c.id)
 }
 
 // Ok sends an OK response to the client.
@@ -59,12 +143,17 @@ func (c *client) Errorf(msg string, args ...interface{}) {
 // IPCServer is a server for inter process communication on a unix socket.
 type IPCServer struct {
 	net.Listener
+	mu          sync.Mutex
+	subscribers map[*client]chan string // queued events of each subscriber
+	clients     uint64                  // number of accepted connections
 }
 
-// NewIPCServer intializes a new IPC server.
-func NewIPCServer() (*IPCServer, error) {
+// NewIPCServer intializes a new IPC server listening on socket.
+func NewIPCServer(socket string) (*IPCServer, error) {
 	var err error
-	ipc := &IPCServer{}
+	ipc := &IPCServer{
+		subscribers: make(map[*client]chan string),
+	}
 	ipc.Listener, err = net.Listen("unix", socket)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start IPC: %s", err)
@@ -85,10 +174,17 @@ func (i *IPCServer) Run(ipcCh chan<- IPCCmd, errCh chan<- error) {
 	for {
 		conn, err := i.Accept()
 		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			errCh <- fmt.Errorf("accept error: %s", err)
+			continue
 		}
+		i.clients++
 		c := &client{
 			Conn:   conn,
+			id:     i.clients,
+			server: i,
 			ipcCh:  ipcCh,
 			errors: errCh,
 		}
@@ -96,17 +192,97 @@ func (i *IPCServer) Run(ipcCh chan<- IPCCmd, errCh chan<- error) {
 	}
 }
 
+// Broadcast queues an event for all subscribed clients without blocking.
+// Clients which can't keep up are dropped.
+func (i *IPCServer) Broadcast(msg string, args ...interface{}) {
+	if i == nil {
+		return
+	}
+
+	event := fmt.Sprintf(msg, args...)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for c, events := range i.subscribers {
+		select {
+		case events <- event:
+		default:
+			slog.Info(fmt.Sprintf("Dropping IPC subscriber %s: too many pending events", c.owner()))
+			delete(i.subscribers, c)
+			close(events)
+			c.Close()
+		}
+	}
+}
+
+// subscribe registers the client for events, which are written to the
+// client by a goroutine of its own.
+func (i *IPCServer) subscribe(c *client) {
+	events := make(chan string, subscriberBuffer)
+
+	i.mu.Lock()
+	i.subscribers[c] = events
+	i.mu.Unlock()
+
+	go func() {
+		for event := range events {
+			c.SetWriteDeadline(time.Now().Add(broadcastTimeout))
+			_, err := fmt.Fprintf(c, "%s\n", event)
+			if err != nil {
+				// the client is unsubscribed once the connection
+				// is closed.
+				c.Close()
+				return
+			}
+		}
+	}()
+}
+
+func (i *IPCServer) unsubscribe(c *client) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if events, ok := i.subscribers[c]; ok {
+		delete(i.subscribers, c)
+		close(events)
+	}
+}
+
+// handleConnection handles the commands of a client until the connection is
+// closed. Inhibitors held by the connection are released when it closes.
 func handleConnection(client *client) {
 	defer client.Close()
 
 	reader := bufio.NewReader(client)
-	line, err := reader.ReadString('\n')
-	if err != nil {
-		client.errors <- fmt.Errorf("unable to read from client: %s", err)
-		return
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err != io.EOF {
+				client.errors <- fmt.Errorf("unable to read from client: %s", err)
+			}
+			break
+		}
+
+		if !handleCommand(client, reader, line[:len(line)-1]) {
+			break
+		}
 	}
 
-	cmd, args := parseCmd(line[:len(line)-1])
+	if client.inhibits {
+		client.ipcCh <- IPCCmd{
+			typ:  IPCRelease,
+			val:  client.owner(),
+			resp: make(chan interface{}, 1),
+		}
+	}
+}
+
+// handleCommand handles a single command. Returns false if the connection
+// should not be read from anymore.
+func handleCommand(client *client, reader *bufio.Reader, line string) bool {
+	var err error
+	cmd, args := parseCmd(line)
 	ipcCmd := IPCCmd{resp: make(chan interface{})}
 	switch cmd {
 	case "SET":
@@ -129,6 +305,14 @@ func handleConnection(client *client) {
 
 		ipcCmd.val = float64(value) / 100
 
+		if len(args) > 1 {
+			ipcCmd.target, err = ParseIPCTarget(args[1])
+			if err != nil {
+				client.Errorf(err.Error())
+				break
+			}
+		}
+
 		switch match[1] {
 		case "":
 			ipcCmd.typ = IPCSet
@@ -147,6 +331,14 @@ func handleConnection(client *client) {
 			client.Ok()
 		}
 	case "STATUS":
+		if len(args) > 0 {
+			ipcCmd.target, err = ParseIPCTarget(args[0])
+			if err != nil {
+				client.Errorf(err.Error())
+				break
+			}
+		}
+
 		ipcCmd.typ = IPCStatus
 		client.ipcCh <- ipcCmd
 
@@ -155,9 +347,31 @@ func handleConnection(client *client) {
 		switch v := status.(type) {
 		case error:
 			client.Errorf(v.Error())
-		case float64:
-			client.OkMsg("%d%%", int(v*100))
+		case ipcStatus:
+			client.OkMsg("%d%% %s", int(math.Round(v.brightness*100)), v.idle)
 		}
+	case "DEVICES":
+		ipcCmd.typ = IPCDevices
+		client.ipcCh <- ipcCmd
+
+		switch v := (<-ipcCmd.resp).(type) {
+		case error:
+			client.Errorf(v.Error())
+		case []*inputDev:
+			// the number of devices is followed by a line per device.
+			client.OkMsg("%d", len(v))
+			for _, d := range v {
+				fmt.Fprintf(client, "%s\n", d)
+			}
+		}
+	case "SUBSCRIBE":
+		client.Ok()
+		client.server.subscribe(client)
+		defer client.server.unsubscribe(client)
+
+		// keep the connection open until closed by the client.
+		io.Copy(ioutil.Discard, reader)
+		return false
 	case "DPMS":
 		if len(args) == 0 {
 			client.Errorf("Missing DPMS argument")
@@ -166,33 +380,83 @@ func handleConnection(client *client) {
 
 		switch args[0] {
 		case "ON":
-			// enable DPMS
+			// turn the displays on
 			ipcCmd.typ = IPCDPMSOn
 			client.ipcCh <- ipcCmd
 			ok := <-ipcCmd.resp
-			if ok.(bool) {
-				client.Ok()
+			if ok != nil {
+				client.Errorf("Failed to set DPMS ON: %s", ok.(error))
 			} else {
-				client.Errorf("Failed to set DPMS ON")
+				client.Ok()
 			}
 		case "OFF":
-			// disable DPMS
+			// turn the displays off
 			ipcCmd.typ = IPCDPMSOff
 			client.ipcCh <- ipcCmd
 			ok := <-ipcCmd.resp
-			if ok.(bool) {
-				client.Ok()
+			if ok != nil {
+				client.Errorf("Failed to set DPMS OFF: %s", ok.(error))
 			} else {
-				client.Errorf("Failed to set DPMS OFF")
+				client.Ok()
 			}
 		default:
 			client.Errorf("Invalid DPMS argument: %s", args[0])
 		}
+	case "INHIBIT":
+		inhibit := ipcInhibit{}
+		if len(args) > 0 && strings.HasPrefix(args[0], "for=") {
+			inhibit.duration, err = time.ParseDuration(strings.TrimPrefix(args[0], "for="))
+			if err != nil || inhibit.duration <= 0 {
+				client.Errorf("Invalid INHIBIT duration: %s", args[0])
+				break
+			}
+			args = args[1:]
+		}
+
+		inhibit.reason = strings.Join(args, " ")
+		if inhibit.reason == "" {
+			client.Errorf("Missing INHIBIT reason")
+			break
+		}
+
+		// without a duration the inhibitor is held until the connection
+		// is closed.
+		if inhibit.duration == 0 {
+			inhibit.owner = client.owner()
+			client.inhibits = true
+		}
+
+		ipcCmd.typ = IPCInhibit
+		ipcCmd.val = inhibit
+		client.ipcCh <- ipcCmd
+		client.OkMsg("%d", (<-ipcCmd.resp).(uint32))
+	case "UNINHIBIT":
+		if len(args) == 0 {
+			client.Errorf("Missing UNINHIBIT argument")
+			break
+		}
+
+		id, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			client.Errorf("Invalid inhibitor: %s", args[0])
+			break
+		}
+
+		ipcCmd.typ = IPCUnInhibit
+		ipcCmd.val = uint32(id)
+		client.ipcCh <- ipcCmd
+		ok := <-ipcCmd.resp
+		if ok != nil {
+			client.Errorf(ok.(error).Error())
+		} else {
+			client.Ok()
+		}
 	default:
 		client.Errorf("Invalid command: %s", cmd)
 	}
 
 	close(ipcCmd.resp)
+	return true
 }
 
 func parseCmd(line string) (string, []string) {
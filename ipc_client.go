@@ -2,43 +2,63 @@ package lis
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
-const socket = "/var/run/lis.sock"
+// DefaultSocket is the default path to the IPC socket.
+const DefaultSocket = "/var/run/lis.sock"
 
 var setPatt = regexp.MustCompile(`(\+|-)?(\d+)%`)
 
 // IPCClient defines an IPC client for communicating with the lis IPC server.
 type IPCClient struct {
 	net.Conn
+	Socket string // path to the IPC socket, DefaultSocket if empty
 }
 
 // RPC sends a message to the IPC server and handles the response.
 func (i *IPCClient) RPC(msg string, args ...interface{}) (interface{}, error) {
-	var err error
-	i.Conn, err = net.Dial("unix", socket)
+	err := i.dial()
 	if err != nil {
 		return nil, err
 	}
 	defer i.Close()
 
-	_, err = i.Write([]byte(fmt.Sprintf(msg+"\n", args)))
+	return i.call(bufio.NewReader(i), msg, args...)
+}
+
+// dial connects to the IPC server.
+func (i *IPCClient) dial() error {
+	socket := i.Socket
+	if socket == "" {
+		socket = DefaultSocket
+	}
+
+	var err error
+	i.Conn, err = net.Dial("unix", socket)
+	return err
+}
+
+// call sends a message on the open connection and handles the response.
+func (i *IPCClient) call(reader *bufio.Reader, msg string, args ...interface{}) (interface{}, error) {
+	_, err := i.Write([]byte(fmt.Sprintf(msg+"\n", args...)))
 	if err != nil {
 		return nil, err
 	}
 
-	reader := bufio.NewReader(i)
 	line, err := reader.ReadString('\n')
 	if err != nil {
 		return nil, err
 	}
 
-	resp := strings.Split(line[:len(line)-1], " ")
+	resp := strings.SplitN(line[:len(line)-1], " ", 2)
 	if len(resp) > 0 {
 		if resp[0] == "OK" {
 			if len(resp) > 1 {
@@ -49,7 +69,7 @@ func (i *IPCClient) RPC(msg string, args ...interface{}) (interface{}, error) {
 
 		if resp[0] == "ERROR" {
 			if len(resp) > 1 {
-				return nil, fmt.Errorf(resp[1])
+				return nil, errors.New(resp[1])
 			}
 		}
 	}
@@ -57,8 +77,9 @@ func (i *IPCClient) RPC(msg string, args ...interface{}) (interface{}, error) {
 	return nil, fmt.Errorf("invalid response: %s", line[:len(line)-1])
 }
 
-// Set sets the brightness value via IPC.
-func (i *IPCClient) Set(value string) error {
+// Set sets the brightness value via IPC. An optional target addresses the
+// device(s) to set e.g. 'panel=intel_backlight'.
+func (i *IPCClient) Set(value, target string) error {
 	match := setPatt.FindStringSubmatch(value)
 	if len(match) == 0 {
 		return fmt.Errorf("invalid SET argument: %s", value)
@@ -73,27 +94,144 @@ func (i *IPCClient) Set(value string) error {
 		return fmt.Errorf("invalid SET argument: %s", value)
 	}
 
+	if target != "" {
+		_, err = ParseIPCTarget(target)
+		if err != nil {
+			return err
+		}
+		_, err = i.RPC("SET %s %s", value, target)
+		return err
+	}
+
 	_, err = i.RPC("SET %s", value)
 	return err
 }
 
-// Status gets the brightness status via IPC.
-func (i *IPCClient) Status() (string, error) {
-	val, err := i.RPC("STATUS")
+// Status gets the brightness status via IPC. An optional target addresses
+// the device to get the status of.
+func (i *IPCClient) Status(target string) (string, error) {
+	if target != "" {
+		_, err := ParseIPCTarget(target)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	val, err := i.RPC(strings.TrimSpace("STATUS " + target))
 	if err != nil {
 		return "", err
 	}
 	return val.(string), err
 }
 
-// DPMS sets the enables/disables DPMS via IPC.
+// Subscribe subscribes to brightness changes and calls fn with each event
+// until the connection is closed by the server.
+func (i *IPCClient) Subscribe(fn func(event string)) error {
+	err := i.dial()
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	reader := bufio.NewReader(i)
+	_, err = i.call(reader, "SUBSCRIBE")
+	if err != nil {
+		return err
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		fn(strings.TrimSuffix(line, "\n"))
+	}
+}
+
+// DPMS turns the displays on or off via IPC.
 func (i *IPCClient) DPMS(value string) error {
-	// TODO: check value
 	switch value {
 	case "on", "off":
-		_, err := i.RPC("DPMS %s", value)
+		_, err := i.RPC("DPMS %s", strings.ToUpper(value))
 		return err
 	default:
 		return fmt.Errorf("invalid value '%s', must be one of 'on, off'", value)
 	}
 }
+
+// Inhibit inhibits idle via IPC and returns the id of the inhibitor. With a
+// duration d the inhibitor is released after d, otherwise it is held until
+// the connection is closed by calling Close.
+func (i *IPCClient) Inhibit(reason string, d time.Duration) (uint32, error) {
+	if reason == "" {
+		return 0, errors.New("missing inhibit reason")
+	}
+
+	var val interface{}
+	var err error
+	if d > 0 {
+		val, err = i.RPC("INHIBIT for=%s %s", d, reason)
+	} else {
+		err = i.dial()
+		if err != nil {
+			return 0, err
+		}
+
+		val, err = i.call(bufio.NewReader(i), "INHIBIT %s", reason)
+		if err != nil {
+			i.Close()
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.ParseUint(fmt.Sprint(val), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid inhibitor: %v", val)
+	}
+
+	return uint32(id), nil
+}
+
+// UnInhibit releases the inhibitor id via IPC.
+func (i *IPCClient) UnInhibit(id uint32) error {
+	_, err := i.RPC("UNINHIBIT %d", id)
+	return err
+}
+
+// Devices lists the input devices via IPC. Each device is described as
+// '<path> <class> <action> <vendor>:<product> <phys> <name>'.
+func (i *IPCClient) Devices() ([]string, error) {
+	err := i.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer i.Close()
+
+	reader := bufio.NewReader(i)
+	val, err := i.call(reader, "DEVICES")
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(fmt.Sprint(val))
+	if err != nil {
+		return nil, fmt.Errorf("invalid number of devices: %v", val)
+	}
+
+	devices := make([]string, 0, n)
+	for len(devices) < n {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, strings.TrimSuffix(line, "\n"))
+	}
+
+	return devices, nil
+}
@@ -0,0 +1,400 @@
+package lis
+
+import (
+	"net"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikkeloscar/lis/internal/sysfstest"
+)
+
+func TestIPC(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 1000, 1000)
+	sysfs.AddBacklight("acpi_video0", "firmware", 100, 100)
+	sysfs.AddLed("tpacpi::kbd_backlight", 2, 2)
+
+	l := newTestLis(t, sysfs, "intel_backlight", "acpi_video0")
+
+	server, err := NewIPCServer(l.socket)
+	if err != nil {
+		t.Fatalf("failed to start IPC server: %s", err)
+	}
+	defer server.Close()
+
+	// stops handling commands before the test returns.
+	done := make(chan struct{})
+	defer close(done)
+
+	go server.Run(l.IPC, l.errors)
+	go func() {
+		for {
+			select {
+			case cmd := <-l.IPC:
+				l.handleIPC(cmd)
+			case err := <-l.errors:
+				t.Errorf("unexpected error: %s", err)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	client := &IPCClient{Socket: l.socket}
+
+	for _, tc := range []struct {
+		value     string
+		target    string
+		subsystem string
+		name      string
+		expected  int
+	}{
+		{"40%", "", "backlight", "intel_backlight", 400},
+		{"40%", "", "backlight", "acpi_video0", 40},
+		{"-15%", "panel=intel_backlight", "backlight", "intel_backlight", 250},
+		{"+5%", "panel", "backlight", "acpi_video0", 45},
+		{"50%", "kbd", "leds", "tpacpi::kbd_backlight", 1},
+	} {
+		err := client.Set(tc.value, tc.target)
+		if err != nil {
+			t.Errorf("SET %s %s failed: %s", tc.value, tc.target, err)
+		}
+
+		if v := sysfs.Brightness(tc.subsystem, tc.name); v != tc.expected {
+			t.Errorf("SET %s %s: expected %s brightness %d, got %d", tc.value, tc.target, tc.name, tc.expected, v)
+		}
+	}
+
+	// the keyboard backlight is only changed when addressed.
+	if v := sysfs.Brightness("leds", "tpacpi::kbd_backlight"); v != 1 {
+		t.Errorf("expected keyboard brightness 1, got %d", v)
+	}
+
+	for _, tc := range []struct {
+		target   string
+		expected string
+	}{
+		{"", "30% stage 0/1"},
+		{"panel=acpi_video0", "45% stage 0/1"},
+		{"kbd", "50% stage 0/1"},
+	} {
+		status, err := client.Status(tc.target)
+		if err != nil {
+			t.Errorf("STATUS %s failed: %s", tc.target, err)
+		}
+
+		if status != tc.expected {
+			t.Errorf("STATUS %s: expected %s, got %s", tc.target, tc.expected, status)
+		}
+	}
+
+	err = client.Set("50%", "panel=nvidia_0")
+	if err == nil {
+		t.Errorf("expected error when addressing unknown device")
+	}
+}
+
+func TestIPCDPMS(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 1000, 1000)
+	t.Setenv("DISPLAY", "")
+
+	l := newTestLis(t, sysfs, BacklightAuto)
+
+	server, err := NewIPCServer(l.socket)
+	if err != nil {
+		t.Fatalf("failed to start IPC server: %s", err)
+	}
+	defer server.Close()
+
+	go server.Run(l.IPC, l.errors)
+	go func() {
+		for cmd := range l.IPC {
+			l.handleIPC(cmd)
+		}
+	}()
+
+	client := &IPCClient{Socket: l.socket}
+
+	for _, tc := range []struct {
+		value    string
+		expected int
+	}{
+		{"off", blPowerOff},
+		{"on", blPowerOn},
+	} {
+		err := client.DPMS(tc.value)
+		if err != nil {
+			t.Errorf("DPMS %s failed: %s", tc.value, err)
+		}
+
+		if v := sysfs.BlPower("intel_backlight"); v != tc.expected {
+			t.Errorf("DPMS %s: expected bl_power %d, got %d", tc.value, tc.expected, v)
+		}
+	}
+
+	err = client.DPMS("standby")
+	if err == nil {
+		t.Errorf("expected error for invalid DPMS value")
+	}
+
+	// bl_power is not written for backlights written through logind.
+	l.devices[0].Writer = fakeWriter{}
+	err = client.DPMS("off")
+	if err == nil {
+		t.Errorf("expected DPMS to fail without a writable bl_power")
+	}
+
+	if v := sysfs.BlPower("intel_backlight"); v != blPowerOn {
+		t.Errorf("expected bl_power %d, got %d", blPowerOn, v)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 1000, 1000)
+
+	l := newTestLis(t, sysfs, BacklightAuto)
+	err := l.loadState()
+	if err != nil {
+		t.Fatalf("failed to load state: %s", err)
+	}
+
+	l.ipc, err = NewIPCServer(l.socket)
+	if err != nil {
+		t.Fatalf("failed to start IPC server: %s", err)
+	}
+	defer l.ipc.Close()
+
+	go l.ipc.Run(l.IPC, l.errors)
+
+	events := make(chan string)
+	go func() {
+		client := &IPCClient{Socket: l.socket}
+		err := client.Subscribe(func(event string) {
+			events <- event
+		})
+		if err != nil {
+			t.Errorf("subscribe failed: %s", err)
+		}
+	}()
+
+	// wait for the subscription to be registered.
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		l.ipc.mu.Lock()
+		n := len(l.ipc.subscribers)
+		l.ipc.mu.Unlock()
+		if n > 0 {
+			break
+		}
+
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("timeout waiting for subscription")
+		}
+	}
+
+	// changes made by lis itself are ignored.
+	l.handleUEvent(UEvent{Action: ueventChange, DevPath: "/devices/intel_backlight", Subsystem: subsystemBacklight})
+	if l.devices[0].current != 1000 {
+		t.Errorf("expected current brightness 1000, got %d", l.devices[0].current)
+	}
+
+	// changes made outside lis are picked up and broadcast.
+	sysfs.SetBrightness("backlight", "intel_backlight", 420)
+	l.handleUEvent(UEvent{Action: ueventChange, DevPath: "/devices/intel_backlight", Subsystem: subsystemBacklight})
+
+	if l.devices[0].current != 420 {
+		t.Errorf("expected current brightness 420, got %d", l.devices[0].current)
+	}
+
+	select {
+	case event := <-events:
+		if event != "BRIGHTNESS panel=intel_backlight 42%" {
+			t.Errorf("unexpected event: %s", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("timeout waiting for event")
+	}
+}
+
+func TestSubscribeStalled(t *testing.T) {
+	socket := path.Join(t.TempDir(), "lis.sock")
+	server, err := NewIPCServer(socket)
+	if err != nil {
+		t.Fatalf("failed to start IPC server: %s", err)
+	}
+	defer server.Close()
+
+	go server.Run(make(chan IPCCmd), make(chan error, 1))
+
+	// the subscriber never reads its events.
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to connect: %s", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("SUBSCRIBE\n"))
+	if err != nil {
+		t.Fatalf("failed to subscribe: %s", err)
+	}
+
+	subscribers := func() int {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		return len(server.subscribers)
+	}
+
+	for start := time.Now(); subscribers() == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("timeout waiting for subscription")
+		}
+	}
+
+	// events larger than the socket buffer stall the writer, broadcasting
+	// must not block and drops the subscriber once its queue is full.
+	event := strings.Repeat("x", 64*1024)
+	start := time.Now()
+	for i := 0; i < 100 && subscribers() > 0; i++ {
+		server.Broadcast("%s", event)
+	}
+
+	if d := time.Since(start); d > broadcastTimeout/2 {
+		t.Errorf("broadcasting was blocked for %s", d)
+	}
+
+	if n := subscribers(); n != 0 {
+		t.Errorf("expected stalled subscriber to be dropped, got %d subscribers", n)
+	}
+}
+
+func TestIPCInhibit(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 1000, 1000)
+
+	l := newTestLis(t, sysfs, BacklightAuto)
+	l.stages = newStages(nil, 1000)
+
+	server, err := NewIPCServer(l.socket)
+	if err != nil {
+		t.Fatalf("failed to start IPC server: %s", err)
+	}
+	defer server.Close()
+
+	go server.Run(l.IPC, l.errors)
+	go func() {
+		for cmd := range l.IPC {
+			l.handleIPC(cmd)
+		}
+	}()
+
+	// waitInhibitors waits until n inhibitors are held.
+	waitInhibitors := func(n int) {
+		t.Helper()
+		for start := time.Now(); len(l.inhibitors.list()) != n; time.Sleep(10 * time.Millisecond) {
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("expected %d inhibitors, got %d", n, len(l.inhibitors.list()))
+			}
+		}
+	}
+
+	// held until the connection is closed.
+	held := &IPCClient{Socket: l.socket}
+	_, err = held.Inhibit("make -j", 0)
+	if err != nil {
+		t.Fatalf("inhibit failed: %s", err)
+	}
+	waitInhibitors(1)
+
+	status, err := (&IPCClient{Socket: l.socket}).Status("")
+	if err != nil {
+		t.Fatalf("status failed: %s", err)
+	}
+
+	if status != "100% stage 0/1, inhibited by ipc: make -j" {
+		t.Errorf("unexpected status: %s", status)
+	}
+
+	held.Close()
+	waitInhibitors(0)
+
+	// released when expired.
+	client := &IPCClient{Socket: l.socket}
+	_, err = client.Inhibit("screencast", 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("inhibit failed: %s", err)
+	}
+	waitInhibitors(1)
+	waitInhibitors(0)
+
+	// released explicitly.
+	id, err := client.Inhibit("screencast", time.Hour)
+	if err != nil {
+		t.Fatalf("inhibit failed: %s", err)
+	}
+	waitInhibitors(1)
+
+	err = client.UnInhibit(id)
+	if err != nil {
+		t.Errorf("uninhibit failed: %s", err)
+	}
+	waitInhibitors(0)
+
+	err = client.UnInhibit(id)
+	if err == nil {
+		t.Errorf("expected error releasing unknown inhibitor")
+	}
+}
+
+func TestIPCDevices(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 1000, 1000)
+
+	l := newTestLis(t, sysfs, BacklightAuto)
+	defer l.closeInputs()
+
+	err := os.MkdirAll(l.inputDir, 0755)
+	if err != nil {
+		t.Fatalf("failed to create input dir: %s", err)
+	}
+
+	// a node which is not an input device is listed as ignored.
+	err = os.WriteFile(path.Join(l.inputDir, "event0"), nil, 0600)
+	if err != nil {
+		t.Fatalf("failed to create device: %s", err)
+	}
+
+	server, err := NewIPCServer(l.socket)
+	if err != nil {
+		t.Fatalf("failed to start IPC server: %s", err)
+	}
+	defer server.Close()
+
+	go server.Run(l.IPC, l.errors)
+	go func() {
+		for cmd := range l.IPC {
+			l.handleIPC(cmd)
+		}
+	}()
+
+	devices, err := (&IPCClient{Socket: l.socket}).Devices()
+	if err != nil {
+		t.Fatalf("devices failed: %s", err)
+	}
+
+	expected := path.Join(l.inputDir, "event0") + " other ignore 0000:0000 - "
+	if len(devices) != 1 || devices[0] != expected {
+		t.Errorf("expected devices [%q], got %q", expected, devices)
+	}
+
+	// listing the devices doesn't start monitoring them.
+	l.inputsMu.Lock()
+	inputs := l.inputs
+	l.inputsMu.Unlock()
+	if inputs != nil {
+		t.Errorf("expected the input devices to be probed without an input manager")
+	}
+}
@@ -2,78 +2,221 @@ package lis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// time to wait before retrying a failed idle source.
+const idleRetryInterval = 5 * time.Second
+
 // Lis defines the core state of the lis daemon.
 type Lis struct {
-	current   uint16        // current brightness value
-	idleMode  bool          // true if in idle mode
-	state     StateFile     // state file
-	backlight *Backlight    // backlight
-	input     chan struct{} // input channel used to notify about activity when in idle mode
-	idle      chan struct{} // idle channel used when user is idle
-	power     chan struct{} // power channel used to notify about power changes (AC/Battery)
+	devices      []*device          // backlight devices
+	stages       []stage            // idle stages
+	stage        int                // number of idle stages entered, 0 if the user is active
+	stageAt      time.Time          // time the current idle stage was entered
+	stageTimer   *time.Timer        // fires when the next idle stage is due
+	locker       chan struct{}      // closed when the locker command exits
+	backlightOff bool               // true if the screen off stage turned off the backlights
+	state        StateFile          // state file
+	input        chan uint64        // input channel used to notify about activity when in idle mode
+	idle         chan uint64        // idle channel used when user is idle
+	listener     context.CancelFunc // cancels the running idle or input listener
+	listenerID   uint64             // id of the running listener, sent on input and idle
+	activityAt   atomic.Int64       // unix time in nanoseconds of simulated activity
+	activity     chan struct{}      // simulated activity e.g. from the ScreenSaver service
+	inhibitors   *inhibitors        // inhibitors preventing the idle stages
+	held         bool               // true if the next idle stage is held back by an inhibitor
+	holdTimer    *time.Timer        // fires when inhibitors should be checked again
+	power        chan struct{}      // power channel used to notify about power changes (AC/Battery)
 	// stop      <-chan struct{} // stop channel used to stop the lis main loop
-	errors   chan error  // errors channel
-	IPC      chan IPCCmd // ipc channel used to communicate with the IPC server
-	idleTime uint        // idle time in minutes
+	errors         chan error       // errors channel
+	uevents        chan UEvent      // uevents of the backlight devices
+	IPC            chan IPCCmd      // ipc channel used to communicate with the IPC server
+	ipc            *IPCServer       // IPC server used to broadcast events to subscribers
+	idleSource     IdleSource       // source used to detect when the user is idle
+	idleSourceName string           // name of the configured idle source
+	inputsMu       sync.Mutex       // guards inputs
+	inputs         *InputManager    // input devices, opened when first needed
+	inputRules     []InputRule      // how the events of input devices are treated
+	inputFilter    ActivityFilter   // which input events count as activity
+	sysfs          string           // mount point of sysfs
+	inputDir       string           // path to the input device dir
+	socket         string           // path to the IPC socket
+	writer         string           // how brightness values are written
+	logind         *LogindWriter    // logind writer, initialized on first use
+	curve          Curve            // brightness curve of display backlights
+	mpris          MPRISConfig      // media players inhibiting idle
+	fullscreen     FullscreenConfig // fullscreen windows inhibiting idle
+	drm            *drmDPMS         // DPMS of the DRM connectors outside X
+
+	// lists the logind inhibitor locks blocking idle
+	logindInhibitors func() ([]logindInhibitor, error)
 }
 
 // NewLis creates a new Lis instance.
 func NewLis(config *Config) (*Lis, error) {
-	var backlightName string
-	switch config.Backlight {
-	case "intel":
-		backlightName = "intel_backlight"
-	case "amdgpu":
-		backlightName = "amdgpu_bl1"
-	default:
-		return nil, fmt.Errorf("backlight: %s not supported", config.Backlight)
+	curve, err := NewCurve(config.Curve, config.Gamma)
+	if err != nil {
+		return nil, err
 	}
 
-	backlight, err := NewBacklight(backlightName)
+	fade, err := config.Fade.Fade()
 	if err != nil {
 		return nil, err
 	}
 
-	return &Lis{
-		idleMode:  false,
-		state:     StateFile(config.StateFile),
-		backlight: backlight,
-		input:     make(chan struct{}),
-		idle:      make(chan struct{}),
-		power:     make(chan struct{}),
-		errors:    make(chan error),
-		IPC:       make(chan IPCCmd),
-		idleTime:  config.IdleTime,
-	}, nil
+	l := &Lis{
+		stages:           newStages(config.Stages, config.IdleTime),
+		state:            StateFile(config.StateFile),
+		input:            make(chan uint64),
+		idle:             make(chan uint64),
+		activity:         make(chan struct{}, 1),
+		inhibitors:       newInhibitors(),
+		logindInhibitors: logindIdleInhibitors,
+		power:            make(chan struct{}),
+		errors:           make(chan error),
+		uevents:          make(chan UEvent),
+		IPC:              make(chan IPCCmd),
+		idleSourceName:   config.IdleSource,
+		sysfs:            config.Sysfs,
+		inputDir:         config.InputDir,
+		socket:           config.Socket,
+		writer:           config.Writer,
+		curve:            curve,
+		mpris:            config.MPRIS,
+		fullscreen:       config.Fullscreen,
+		drm:              &drmDPMS{dir: config.DRMDir},
+		inputRules:       config.InputRules,
+		inputFilter:      config.Activity.Filter(),
+	}
+
+	min, max := minLevel, maxLevel
+	if config.Brightness.Min != nil {
+		min = *config.Brightness.Min
+	}
+
+	if config.Brightness.Max != nil {
+		max = *config.Brightness.Max
+	}
+
+	dimTo := min
+	if config.Brightness.Dim != nil {
+		dimTo = *config.Brightness.Dim
+	}
+
+	for _, selector := range config.Backlight {
+		var name string
+		err := waitForDevice(func() error {
+			var err error
+			name, err = ResolveBacklight(config.Sysfs, selector)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if l.device(subsystemBacklight, name) != nil {
+			continue
+		}
+
+		slog.Info(fmt.Sprintf("Using backlight device: %s", name))
+
+		d := &device{
+			class:    classPanel,
+			selector: selector,
+			min:      min,
+			max:      max,
+			dimTo:    dimTo,
+			fade:     fade,
+		}
+
+		err = l.bind(d, name, true)
+		if err != nil {
+			return nil, err
+		}
+
+		l.devices = append(l.devices, d)
+	}
+
+	if config.Keyboard != BacklightNone {
+		name, err := ResolveKeyboardBacklight(config.Sysfs, config.Keyboard)
+		if err != nil {
+			return nil, err
+		}
+
+		// the keyboard backlight is switched off when dimming.
+		d := &device{
+			class:    classKbd,
+			selector: config.Keyboard,
+			min:      minLevel,
+			max:      maxLevel,
+			dimTo:    minLevel,
+			fade:     fade,
+		}
+
+		// a keyboard backlight connected later, e.g. by a dock, is
+		// bound when it appears.
+		if name != "" {
+			slog.Info(fmt.Sprintf("Using keyboard backlight device: %s", name))
+
+			err = l.bind(d, name, true)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			slog.Info("No keyboard backlight device found, waiting for one to appear")
+		}
+
+		l.devices = append(l.devices, d)
+	}
+
+	return l, nil
 }
 
 // load state from stateFile.
 func (l *Lis) loadState() error {
-	v, err := l.state.Read()
+	state, err := l.state.Read()
 	if err != nil {
 		// if state files was not found set brightness to max value
-		if os.IsNotExist(err) {
-			max, err := l.backlight.ReadMax()
+		if !os.IsNotExist(err) {
+			return err
+		}
+		state = make(State)
+	}
+
+	for i, d := range l.devices {
+		if !d.bound {
+			continue
+		}
+
+		v, ok := state[d.Name]
+		if !ok && i == 0 {
+			// state written by older versions of lis only stores the
+			// value of a single device.
+			v, ok = state[legacyState]
+		}
+
+		if !ok {
+			max, err := d.ReadMax()
 			if err != nil {
 				return err
 			}
 			v = uint16(max)
-		} else {
-			return err
 		}
-	}
 
-	l.current = v
+		d.current = uint16(d.clamp(int(v)))
 
-	err = l.backlight.Set(int(l.current))
-	if err != nil {
-		return err
+		err = d.Set(int(d.current))
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -81,38 +224,24 @@ func (l *Lis) loadState() error {
 
 // store current state in stateFile.
 func (l *Lis) storeState() error {
-	if l.idleMode {
-		err := l.state.Write(l.current)
-		if err != nil {
-			return err
+	state := make(State, len(l.devices))
+	for _, d := range l.devices {
+		// the device has never been bound.
+		if d.Backlight == nil {
+			continue
 		}
 
-		return nil
-	}
-
-	err := l.getCurrent()
-	if err != nil {
-		return err
-	}
+		if !l.isIdle() && d.bound {
+			err := d.refresh()
+			if err != nil {
+				return err
+			}
+		}
 
-	err = l.state.Write(l.current)
-	if err != nil {
-		return err
+		state[d.Name] = d.current
 	}
 
-	return nil
-}
-
-// get current brightness level.
-func (l *Lis) getCurrent() error {
-	v, err := l.backlight.Get()
-	if err != nil {
-		return err
-	}
-
-	l.current = uint16(v)
-
-	return nil
+	return l.state.Write(state)
 }
 
 // Run runs the lis main loop.
@@ -132,80 +261,84 @@ func (l *Lis) Run(ctx context.Context) error {
 	defer dbus.Close()
 
 	// start IPC server
-	ipc, err := NewIPCServer()
+	ipc, err := NewIPCServer(l.socket)
 	if err != nil {
 		return err
 	}
 
 	go ipc.Run(l.IPC, l.errors)
 	defer ipc.Close()
+	l.ipc = ipc
+
+	// listen for brightness changes made outside of lis
+	uevents, err := NewUEventListener()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Unable to detect brightness changes: %s", err))
+	} else {
+		go uevents.Run(l.uevents, l.errors)
+		defer uevents.Close()
+	}
+
+	// provide the ScreenSaver service to applications inhibiting idle
+	screenSaver, err := l.startScreenSaver()
+	if err != nil {
+		slog.Error(fmt.Sprintf("Unable to provide %s: %s", screenSaverName, err))
+	} else {
+		defer screenSaver.Close()
+	}
+
+	// inhibit idle while media players are playing
+	if l.mpris.Enable {
+		mpris, err := l.startMPRIS()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Unable to watch media players: %s", err))
+		} else {
+			defer mpris.Close()
+		}
+	}
+
+	l.idleSource, err = NewIdleSource(l.idleSourceName, IdleSourceOptions{Inputs: l.inputManager})
+	if err != nil {
+		return err
+	}
+	defer l.idleSource.Close()
+	defer l.closeInputs()
 
 	// start Listening for idle
-	l.idleListener()
+	l.idleListener(ctx)
 
 	for {
 		select {
-		case <-l.input:
-			// undim screen
-			l.unDim()
-			l.idleMode = false
-
-			// start Listening for idle
-			l.idleListener()
-		case <-l.idle:
-			slog.Info("Entering idle mode")
+		case id := <-l.input:
+			if id != l.listenerID {
+				break
+			}
 
-			// dim screen
-			l.dim()
-			l.idleMode = true
+			// leave the idle stages
+			l.resume()
 
-			// start Listening for input to exit idle mode
-			err = l.inputListener()
-			if err != nil {
-				slog.Error(err.Error())
-				continue
+			// start Listening for idle
+			l.idleListener(ctx)
+		case id := <-l.idle:
+			if id != l.listenerID {
+				break
 			}
+
+			l.idleDue(ctx)
+		case <-l.stageTimeout():
+			l.idleDue(ctx)
+		case <-l.activity:
+			l.simulateActivity(ctx)
+		case <-l.inhibitors.changed:
+			l.checkHeld(ctx)
+		case <-l.holdTimeout():
+			l.checkHeld(ctx)
 		case power := <-l.power:
 			fmt.Println("power", power)
 		case ipc := <-l.IPC:
-			switch ipc.typ {
-			case IPCSet:
-				err = l.SetPercent(ipc.val.(float64))
-				if err != nil {
-					slog.Error(fmt.Sprintf("Failed to set brightness value: %v", err))
-				}
-				ipc.resp <- err
-			case IPCSetUp, IPCSetDown:
-				current, err := l.GetPercent()
-				if err != nil {
-					slog.Error(fmt.Sprintf("Failed to get brightness value: %v", err))
-				} else {
-					var value float64
-					switch ipc.typ {
-					case IPCSetUp:
-						value = clampPct(current + ipc.val.(float64))
-					case IPCSetDown:
-						value = clampPct(current - ipc.val.(float64))
-					}
-					err = l.SetPercent(value)
-					if err != nil {
-						slog.Error(fmt.Sprintf("Failed to set brightness value: %v", err))
-					}
-				}
-
-				ipc.resp <- err
-			case IPCStatus:
-				val, err := l.GetPercent()
-				if err != nil {
-					slog.Error(fmt.Sprintf("Failed to get brightness value: %s", err))
-					ipc.resp <- err
-				} else {
-					ipc.resp <- val
-				}
-			case IPCDPMSOn:
-			case IPCDPMSOff:
-			}
-
+			l.handleIPC(ipc)
+		case event := <-l.uevents:
+			l.handleUEvent(event)
 		case err := <-l.errors:
 			// Write error to stderr
 			fmt.Fprintln(os.Stderr, err.Error())
@@ -215,73 +348,335 @@ func (l *Lis) Run(ctx context.Context) error {
 	}
 }
 
-// GetPercent gets the current backlight value as a percent value.
-// (max/current).
-func (l *Lis) GetPercent() (float64, error) {
-	val, err := l.backlight.Get()
-	if err != nil {
-		return 0, err
+// handleIPC handles a command received by the IPC server.
+func (l *Lis) handleIPC(ipc IPCCmd) {
+	switch ipc.typ {
+	case IPCDPMSOn, IPCDPMSOff:
+		err := l.setDPMS(ipc.typ == IPCDPMSOn)
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to set DPMS: %s", err))
+		}
+		ipc.resp <- err
+		return
+	case IPCInhibit, IPCUnInhibit, IPCRelease:
+		l.handleInhibit(ipc)
+		return
+	case IPCDevices:
+		devices, err := l.inputDevices()
+		if err != nil {
+			ipc.resp <- err
+			return
+		}
+		ipc.resp <- devices
+		return
 	}
 
-	return float64(val) / float64(l.backlight.Max), nil
+	// commands without a target addresses the display backlights.
+	if ipc.target == nil {
+		ipc.target = &IPCTarget{Class: classPanel}
+	}
+
+	devices := l.targetDevices(ipc.target)
+	if len(devices) == 0 {
+		ipc.resp <- fmt.Errorf("no such device: %s", ipc.target)
+		return
+	}
+
+	switch ipc.typ {
+	case IPCSet:
+		var err error
+		for _, d := range devices {
+			err = d.setPercent(ipc.val.(float64))
+			if err != nil {
+				slog.Error(fmt.Sprintf("Failed to set brightness value of %s: %v", d.Name, err))
+				break
+			}
+			l.broadcast(d)
+		}
+		ipc.resp <- err
+	case IPCSetUp, IPCSetDown:
+		delta := ipc.val.(float64)
+		if ipc.typ == IPCSetDown {
+			delta = -delta
+		}
+
+		var err error
+		for _, d := range devices {
+			err = d.stepPercent(delta)
+			if err != nil {
+				slog.Error(fmt.Sprintf("Failed to set brightness value of %s: %v", d.Name, err))
+				break
+			}
+			l.broadcast(d)
+		}
+
+		ipc.resp <- err
+	case IPCStatus:
+		// report the status of the first addressed device.
+		val, err := devices[0].getPercent()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to get brightness value: %s", err))
+			ipc.resp <- err
+		} else {
+			ipc.resp <- ipcStatus{brightness: val, idle: l.idleStatus()}
+		}
+	}
 }
 
-// SetPercent sets the current value from a percent value. (max * value).
-func (l *Lis) SetPercent(value float64) error {
-	if value > 1 || value < 0 {
-		return fmt.Errorf("invalid percent value: %f", value)
+// handleUEvent handles a uevent of a backlight or leds device.
+func (l *Lis) handleUEvent(event UEvent) {
+	if event.Action == ueventAdd {
+		l.rebind()
+		return
+	}
+
+	d := l.device(event.Subsystem, event.Name())
+	if d == nil {
+		return
 	}
 
-	val := int(float64(l.backlight.Max) * value)
-	l.current = uint16(val)
-	return l.backlight.Set(val)
+	switch event.Action {
+	case ueventRemove:
+		l.unbind(d)
+		// another device may be able to take its place.
+		l.rebind()
+	case ueventChange:
+		// brightness changes are only tracked when the user is active and
+		// lis is not fading the device.
+		if l.isIdle() || d.fader.running() {
+			return
+		}
+
+		v, err := d.Get()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Failed to get brightness value of %s: %s", d.Name, err))
+			return
+		}
+
+		// ignore changes made by lis itself.
+		if v == d.LastSet() || v == int(d.current) {
+			return
+		}
+
+		slog.Info(fmt.Sprintf("Brightness of %s changed to %d", d.Name, v))
+		d.current = uint16(v)
+		l.broadcast(d)
+	}
+}
+
+// broadcast the brightness level of a device to IPC subscribers.
+func (l *Lis) broadcast(d *device) {
+	l.ipc.Broadcast("BRIGHTNESS %s=%s %d%%", d.class, d.Name,
+		int(math.Round(d.Curve.FromRaw(int(d.current), d.Max)*100)))
 }
 
-// dim screen.
-func (l *Lis) dim() {
-	slog.Info(fmt.Sprintf("Dimming screen from brightness level %d to %d", l.current, 0))
-	go l.backlight.Dim(int(l.current), 0, l.errors)
+// device returns the bound device name in subsystem or nil if not found.
+func (l *Lis) device(subsystem, name string) *device {
+	for _, d := range l.devices {
+		if d.bound && d.Subsystem == subsystem && d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// targetDevices returns the devices addressed by an IPC target.
+func (l *Lis) targetDevices(target *IPCTarget) []*device {
+	devices := make([]*device, 0, len(l.devices))
+	for _, d := range l.devices {
+		if d.bound && d.matches(target) {
+			devices = append(devices, d)
+		}
+	}
+	return devices
+}
+
+// dim screen to level, or the configured dim level of each device if nil.
+func (l *Lis) dim(level *Level) {
+	for _, d := range l.devices {
+		if d.bound {
+			d.dim(level, l.errors)
+		}
+	}
 }
 
 // undim screen.
 func (l *Lis) unDim() {
-	slog.Info(fmt.Sprintf("Undimming screen to brightness level %d to %d", 0, l.current))
-	go l.backlight.UnDim(0, int(l.current), l.errors)
+	for _, d := range l.devices {
+		if d.bound {
+			d.unDim(l.errors)
+		}
+	}
 }
 
-// listen for input activity.
-func (l *Lis) inputListener() error {
-	devices, err := GetInputDevices(l.errors)
-	if err != nil {
-		return err
+// listen runs fn as the only listener for idle or input, cancelling the
+// previous listener. Notifications of cancelled listeners are ignored by
+// the main loop.
+func (l *Lis) listen(ctx context.Context, fn func(ctx context.Context, id uint64)) {
+	if l.listener != nil {
+		l.listener()
 	}
 
-	go devices.Wait(l.input)
+	ctx, l.listener = context.WithCancel(ctx)
+	l.listenerID++
+	go fn(ctx, l.listenerID)
+}
 
-	return nil
+// listen for input activity. The idle source is used if it can detect
+// activity, otherwise the input devices are monitored.
+func (l *Lis) inputListener(ctx context.Context) {
+	l.listen(ctx, func(ctx context.Context, id uint64) {
+		err := l.idleSource.WaitActive(ctx)
+		switch err {
+		case nil:
+			l.notify(ctx, l.input, id)
+			return
+		case ErrActivityUnsupported:
+		default:
+			if ctx.Err() != nil {
+				return
+			}
+			l.error(ctx, err)
+		}
+
+		inputs, err := l.inputManager()
+		if err != nil {
+			l.error(ctx, err)
+			return
+		}
+
+		if inputs.Wait(ctx) == nil {
+			l.notify(ctx, l.input, id)
+		}
+	})
 }
 
-// listen for user idling.
-func (l *Lis) idleListener() {
-	go l.xidle()
+// closeInputs closes the input devices if opened.
+func (l *Lis) closeInputs() {
+	l.inputsMu.Lock()
+	defer l.inputsMu.Unlock()
+
+	if l.inputs != nil {
+		l.inputs.Close()
+		l.inputs = nil
+	}
 }
 
-// listen for X idletime.
-func (l *Lis) xidle() {
-	for {
-		time.Sleep(time.Duration(l.idleTime/3) * time.Millisecond)
-		idleTime, err := XIdle()
+// inputManager returns the input devices monitored when the idle source
+// can't detect activity. The devices are opened on first use and kept open.
+func (l *Lis) inputManager() (*InputManager, error) {
+	l.inputsMu.Lock()
+	defer l.inputsMu.Unlock()
+
+	if l.inputs == nil {
+		inputs, err := NewInputManager(l.inputDir, l.inputRules, l.inputFilter)
 		if err != nil {
-			l.errors <- err
-			continue
+			return nil, err
 		}
+		l.inputs = inputs
+	}
+
+	return l.inputs, nil
+}
+
+// inputDevices returns the input devices of the input manager. If no
+// input manager is used the devices are probed instead, such that listing
+// them doesn't keep the devices open.
+func (l *Lis) inputDevices() ([]*inputDev, error) {
+	l.inputsMu.Lock()
+	inputs := l.inputs
+	l.inputsMu.Unlock()
+
+	if inputs != nil {
+		return inputs.devices(true), nil
+	}
+
+	return probeInputDevices(l.inputDir, l.inputRules)
+}
+
+// listen for user idling until the first idle stage is due.
+func (l *Lis) idleListener(ctx context.Context) {
+	timeout := l.stages[0].timeout
+	l.listen(ctx, func(ctx context.Context, id uint64) {
+		for {
+			err := l.idleSource.WaitIdle(ctx, timeout)
+			if err == nil {
+				err = l.waitSimulated(ctx, timeout)
+			}
+
+			if err == nil {
+				l.notify(ctx, l.idle, id)
+				return
+			}
 
-		slog.Info(fmt.Sprintf("Idling for %s", time.Duration(idleTime)*time.Millisecond))
+			if ctx.Err() != nil {
+				return
+			}
 
-		if idleTime >= l.idleTime {
-			l.idle <- struct{}{}
-			break
+			if err == errActive {
+				continue
+			}
+
+			// retry after a while if the idle source failed.
+			l.error(ctx, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(idleRetryInterval):
+			}
 		}
+	})
+}
+
+// errActive is returned by waitSimulated if the user became active.
+var errActive = errors.New("user became active")
+
+// waitSimulated waits until timeout has passed since the last simulated
+// activity, which the idle source doesn't know about. Returns errActive if
+// the user becomes active in the meantime.
+func (l *Lis) waitSimulated(ctx context.Context, timeout time.Duration) error {
+	remaining := timeout - time.Since(time.Unix(0, l.activityAt.Load()))
+	if remaining <= 0 {
+		return nil
+	}
+
+	wctx, cancel := context.WithTimeout(ctx, remaining)
+	defer cancel()
+
+	err := l.idleSource.WaitActive(wctx)
+	switch {
+	case err == nil:
+		return errActive
+	case err == ErrActivityUnsupported:
+		<-wctx.Done()
+	}
+
+	// the context of the listener was cancelled.
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if wctx.Err() == context.DeadlineExceeded {
+		return nil
+	}
+
+	return err
+}
+
+// notify sends a notification from listener id to the main loop unless
+// ctx is cancelled.
+func (l *Lis) notify(ctx context.Context, ch chan uint64, id uint64) {
+	select {
+	case ch <- id:
+	case <-ctx.Done():
+	}
+}
+
+// error sends an error to the main loop unless ctx is cancelled.
+func (l *Lis) error(ctx context.Context, err error) {
+	select {
+	case l.errors <- err:
+	case <-ctx.Done():
 	}
 }
 
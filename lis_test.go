@@ -0,0 +1,542 @@
+package lis
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/mikkeloscar/lis/internal/sysfstest"
+)
+
+// newTestLis sets up a Lis instance controlling the devices of a fake
+// sysfs tree.
+func newTestLis(t *testing.T, sysfs *sysfstest.Sysfs, backlight ...string) *Lis {
+	t.Helper()
+
+	dir := t.TempDir()
+	l, err := NewLis(&Config{
+		StateFile: path.Join(dir, "state"),
+		Backlight: backlight,
+		Keyboard:  BacklightAuto,
+		Writer:    WriterSysfs,
+		Sysfs:     sysfs.Root,
+		InputDir:  path.Join(dir, "input"),
+		DRMDir:    path.Join(dir, "dri"),
+		Socket:    path.Join(dir, "lis.sock"),
+	})
+	if err != nil {
+		t.Fatalf("failed to setup lis: %s", err)
+	}
+
+	return l
+}
+
+func TestLoadStoreState(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 937, 500)
+	sysfs.AddBacklight("acpi_video0", "firmware", 15, 15)
+	sysfs.AddLed("tpacpi::kbd_backlight", 2, 2)
+
+	l := newTestLis(t, sysfs, "intel_backlight", "acpi_video0")
+	if len(l.devices) != 3 {
+		t.Fatalf("expected 3 devices, got %d", len(l.devices))
+	}
+
+	// without a state file all devices are set to max.
+	err := l.loadState()
+	if err != nil {
+		t.Fatalf("failed to load state: %s", err)
+	}
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 937 {
+		t.Errorf("expected brightness 937, got %d", v)
+	}
+
+	sysfs.SetBrightness("backlight", "intel_backlight", 300)
+	sysfs.SetBrightness("backlight", "acpi_video0", 7)
+	sysfs.SetBrightness("leds", "tpacpi::kbd_backlight", 1)
+
+	err = l.storeState()
+	if err != nil {
+		t.Fatalf("failed to store state: %s", err)
+	}
+
+	state, err := l.state.Read()
+	if err != nil {
+		t.Fatalf("failed to read state: %s", err)
+	}
+
+	expected := State{"intel_backlight": 300, "acpi_video0": 7, "tpacpi::kbd_backlight": 1}
+	for name, v := range expected {
+		if state[name] != v {
+			t.Errorf("expected state %d for %s, got %d", v, name, state[name])
+		}
+	}
+
+	sysfs.SetBrightness("backlight", "intel_backlight", 0)
+	sysfs.SetBrightness("backlight", "acpi_video0", 0)
+	sysfs.SetBrightness("leds", "tpacpi::kbd_backlight", 0)
+
+	err = l.loadState()
+	if err != nil {
+		t.Fatalf("failed to load state: %s", err)
+	}
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 300 {
+		t.Errorf("expected brightness 300, got %d", v)
+	}
+
+	if v := sysfs.Brightness("backlight", "acpi_video0"); v != 7 {
+		t.Errorf("expected brightness 7, got %d", v)
+	}
+
+	if v := sysfs.Brightness("leds", "tpacpi::kbd_backlight"); v != 1 {
+		t.Errorf("expected brightness 1, got %d", v)
+	}
+}
+
+func TestDim(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 937, 500)
+	sysfs.AddLed("tpacpi::kbd_backlight", 2, 2)
+
+	l := newTestLis(t, sysfs, BacklightAuto)
+	for _, d := range l.devices {
+		err := d.refresh()
+		if err != nil {
+			t.Fatalf("failed to read brightness: %s", err)
+		}
+	}
+
+	errCh := make(chan error, 10)
+	l.errors = errCh
+
+	l.dim(nil)
+	for _, d := range l.devices {
+		d.fader.wait()
+	}
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 0 {
+		t.Errorf("expected brightness 0, got %d", v)
+	}
+
+	if v := sysfs.Brightness("leds", "tpacpi::kbd_backlight"); v != 0 {
+		t.Errorf("expected keyboard brightness 0, got %d", v)
+	}
+
+	l.unDim()
+	for _, d := range l.devices {
+		d.fader.wait()
+	}
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 500 {
+		t.Errorf("expected brightness 500, got %d", v)
+	}
+
+	if v := sysfs.Brightness("leds", "tpacpi::kbd_backlight"); v != 2 {
+		t.Errorf("expected keyboard brightness 2, got %d", v)
+	}
+
+	// undimming in the middle of a dim cancels the dim.
+	for _, d := range l.devices {
+		d.fade.Duration = time.Second
+	}
+
+	l.dim(nil)
+	time.Sleep(100 * time.Millisecond)
+	l.unDim()
+	for _, d := range l.devices {
+		d.fader.wait()
+	}
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 500 {
+		t.Errorf("expected brightness 500, got %d", v)
+	}
+
+	select {
+	case err := <-errCh:
+		t.Errorf("unexpected error: %s", err)
+	default:
+	}
+}
+
+func TestStepPercentCurve(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 937, 937)
+
+	l := newTestLis(t, sysfs, BacklightAuto)
+	d := l.devices[0]
+	d.Curve = logCurve{}
+
+	err := d.setPercent(0.5)
+	if err != nil {
+		t.Fatalf("failed to set brightness: %s", err)
+	}
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 30 {
+		t.Errorf("expected brightness 30, got %d", v)
+	}
+
+	pct, err := d.getPercent()
+	if err != nil {
+		t.Fatalf("failed to get brightness: %s", err)
+	}
+
+	if int(pct*100+0.5) != 50 {
+		t.Errorf("expected 50%%, got %f", pct)
+	}
+
+	// small steps at the bottom of the curve still change the brightness.
+	err = d.setPercent(0)
+	if err != nil {
+		t.Fatalf("failed to set brightness: %s", err)
+	}
+
+	err = d.stepPercent(0.01)
+	if err != nil {
+		t.Fatalf("failed to step brightness: %s", err)
+	}
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 1 {
+		t.Errorf("expected brightness 1, got %d", v)
+	}
+}
+
+func TestHotplug(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("amdgpu_bl0", "raw", 255, 255)
+
+	l := newTestLis(t, sysfs, "amdgpu")
+	err := l.loadState()
+	if err != nil {
+		t.Fatalf("failed to load state: %s", err)
+	}
+
+	d := l.devices[0]
+	err = d.setPercent(0.6)
+	if err != nil {
+		t.Fatalf("failed to set brightness: %s", err)
+	}
+
+	// the driver is reloaded and the device renumbered.
+	sysfs.Remove("backlight", "amdgpu_bl0")
+	l.handleUEvent(UEvent{Action: ueventRemove, DevPath: "/devices/amdgpu_bl0", Subsystem: subsystemBacklight})
+
+	if d.bound {
+		t.Fatalf("expected device to be unbound")
+	}
+
+	if devices := l.targetDevices(&IPCTarget{Class: classPanel}); len(devices) != 0 {
+		t.Errorf("expected no addressable devices, got %d", len(devices))
+	}
+
+	sysfs.AddBacklight("amdgpu_bl1", "raw", 1000, 0)
+	l.handleUEvent(UEvent{Action: ueventAdd, DevPath: "/devices/amdgpu_bl1", Subsystem: subsystemBacklight})
+
+	if !d.bound || d.Name != "amdgpu_bl1" {
+		t.Fatalf("expected device to be bound to amdgpu_bl1, got %s (bound: %t)", d.Name, d.bound)
+	}
+
+	if v := sysfs.Brightness("backlight", "amdgpu_bl1"); v != 600 {
+		t.Errorf("expected brightness to be restored to 600, got %d", v)
+	}
+
+	err = l.storeState()
+	if err != nil {
+		t.Fatalf("failed to store state: %s", err)
+	}
+
+	state, err := l.state.Read()
+	if err != nil {
+		t.Fatalf("failed to read state: %s", err)
+	}
+
+	if state["amdgpu_bl1"] != 600 {
+		t.Errorf("expected state 600 for amdgpu_bl1, got %d", state["amdgpu_bl1"])
+	}
+}
+
+func TestHotplugKeyboard(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 1000, 1000)
+
+	l := newTestLis(t, sysfs, BacklightAuto)
+	err := l.loadState()
+	if err != nil {
+		t.Fatalf("failed to load state: %s", err)
+	}
+
+	// the keyboard backlight is missing at startup.
+	if devices := l.targetDevices(&IPCTarget{Class: classKbd}); len(devices) != 0 {
+		t.Fatalf("expected no keyboard backlight, got %d", len(devices))
+	}
+
+	err = l.storeState()
+	if err != nil {
+		t.Fatalf("failed to store state: %s", err)
+	}
+
+	// a keyboard with a backlight is connected.
+	sysfs.AddLed("tpacpi::kbd_backlight", 2, 1)
+	l.handleUEvent(UEvent{Action: ueventAdd, DevPath: "/devices/tpacpi::kbd_backlight", Subsystem: subsystemLeds})
+
+	devices := l.targetDevices(&IPCTarget{Class: classKbd})
+	if len(devices) != 1 || devices[0].Name != "tpacpi::kbd_backlight" {
+		t.Fatalf("expected keyboard backlight to be bound, got %d devices", len(devices))
+	}
+
+	// the brightness of the new device is kept.
+	if v := sysfs.Brightness("leds", "tpacpi::kbd_backlight"); v != 1 {
+		t.Errorf("expected keyboard brightness 1, got %d", v)
+	}
+
+	if devices[0].current != 1 {
+		t.Errorf("expected current keyboard brightness 1, got %d", devices[0].current)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 1000, 500)
+
+	l := newTestLis(t, sysfs, BacklightAuto)
+	err := l.loadState()
+	if err != nil {
+		t.Fatalf("failed to load state: %s", err)
+	}
+
+	d := l.devices[0]
+	d.min = Level{Value: 50}
+	d.max = Level{Value: 0.9, Percent: true}
+	d.dimTo = Level{Value: 0.1, Percent: true}
+
+	for _, tc := range []struct {
+		set      func() error
+		expected int
+	}{
+		{func() error { return d.setPercent(0) }, 50},
+		{func() error { return d.stepPercent(-0.1) }, 50},
+		{func() error { return d.setPercent(1) }, 900},
+		{func() error { return d.stepPercent(0.1) }, 900},
+		{func() error { return d.setPercent(0.5) }, 500},
+	} {
+		err := tc.set()
+		if err != nil {
+			t.Errorf("should not cause error: %s", err)
+		}
+
+		if v := sysfs.Brightness("backlight", "intel_backlight"); v != tc.expected {
+			t.Errorf("expected brightness %d, got %d", tc.expected, v)
+		}
+	}
+
+	if v := d.dimLevel(nil); v != 100 {
+		t.Errorf("expected dim level 100, got %d", v)
+	}
+
+	// restored state is clamped.
+	err = l.state.Write(State{"intel_backlight": 0})
+	if err != nil {
+		t.Fatalf("failed to write state: %s", err)
+	}
+
+	err = l.loadState()
+	if err != nil {
+		t.Fatalf("failed to load state: %s", err)
+	}
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 50 {
+		t.Errorf("expected restored brightness 50, got %d", v)
+	}
+
+	// dimming never brightens the device.
+	if v := d.dimLevel(nil); v != 50 {
+		t.Errorf("expected dim level 50, got %d", v)
+	}
+}
+
+// fakeIdleSource is an idle source which never reports idle or activity.
+type fakeIdleSource struct{}
+
+func (fakeIdleSource) WaitIdle(ctx context.Context, timeout time.Duration) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (fakeIdleSource) WaitActive(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (fakeIdleSource) Close() error {
+	return nil
+}
+
+func TestIdleStages(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 1000, 500)
+	sysfs.AddLed("tpacpi::kbd_backlight", 2, 2)
+
+	locked := path.Join(t.TempDir(), "locked")
+	t.Setenv("DISPLAY", "")
+
+	l := newTestLis(t, sysfs, BacklightAuto)
+	l.stages = newStages([]IdleStage{
+		{Timeout: 1000, Action: StageDim, Level: &Level{Value: 0.2, Percent: true}},
+		{Timeout: 2000, Action: StageScreenOff},
+		{Timeout: 3000, Action: StageLock, Command: StringList{"touch " + locked}},
+	}, 0)
+	l.idleSource = fakeIdleSource{}
+	l.errors = make(chan error, 10)
+
+	for _, d := range l.devices {
+		err := d.refresh()
+		if err != nil {
+			t.Fatalf("failed to read brightness: %s", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	wait := func() {
+		for _, d := range l.devices {
+			d.fader.wait()
+		}
+	}
+
+	if s := l.idleStatus().String(); s != "stage 0/3" {
+		t.Errorf("unexpected status: %s", s)
+	}
+
+	// the first stage dims the display backlight to the stage level and
+	// the keyboard backlight to its own dim level.
+	l.nextStage(ctx)
+	wait()
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 200 {
+		t.Errorf("expected brightness 200, got %d", v)
+	}
+
+	if v := sysfs.Brightness("leds", "tpacpi::kbd_backlight"); v != 0 {
+		t.Errorf("expected keyboard brightness 0, got %d", v)
+	}
+
+	if s := l.idleStatus().String(); s != "stage 1/3, 1s until screenoff" {
+		t.Errorf("unexpected status: %s", s)
+	}
+
+	// the display is turned off through DPMS.
+	l.nextStage(ctx)
+	wait()
+
+	if v := sysfs.BlPower("intel_backlight"); v != blPowerOff {
+		t.Errorf("expected bl_power %d, got %d", blPowerOff, v)
+	}
+
+	l.nextStage(ctx)
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(locked); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := os.Stat(locked); err != nil {
+		t.Errorf("locker was not run: %s", err)
+	}
+
+	if s := l.idleStatus().String(); s != "stage 3/3" {
+		t.Errorf("unexpected status: %s", s)
+	}
+
+	// activity unwinds all stages.
+	l.resume()
+	wait()
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 500 {
+		t.Errorf("expected brightness 500, got %d", v)
+	}
+
+	if v := sysfs.Brightness("leds", "tpacpi::kbd_backlight"); v != 2 {
+		t.Errorf("expected keyboard brightness 2, got %d", v)
+	}
+
+	if v := sysfs.BlPower("intel_backlight"); v != blPowerOn {
+		t.Errorf("expected bl_power %d, got %d", blPowerOn, v)
+	}
+
+	if l.isIdle() || l.stageTimer != nil {
+		t.Errorf("expected to leave the idle stages")
+	}
+}
+
+func TestInhibit(t *testing.T) {
+	sysfs := sysfstest.New(t)
+	sysfs.AddBacklight("intel_backlight", "raw", 1000, 500)
+
+	var locks []logindInhibitor
+	l := newTestLis(t, sysfs, BacklightAuto)
+	l.idleSource = fakeIdleSource{}
+	l.errors = make(chan error, 10)
+	l.logindInhibitors = func() ([]logindInhibitor, error) {
+		return locks, nil
+	}
+
+	err := l.devices[0].refresh()
+	if err != nil {
+		t.Fatalf("failed to read brightness: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// an inhibitor holds back the idle stages.
+	id := l.inhibitors.add(inhibitScreenSaver, ":1.42", "mpv", "Playing video")
+	l.idleDue(ctx)
+	if l.isIdle() || !l.held {
+		t.Fatalf("expected idle to be inhibited")
+	}
+
+	if s := l.idleStatus().String(); s != "stage 0/1, inhibited by mpv (screensaver: Playing video)" {
+		t.Errorf("unexpected status: %s", s)
+	}
+
+	l.inhibitors.remove(inhibitScreenSaver, id)
+	<-l.inhibitors.changed
+	l.checkHeld(ctx)
+	if l.held {
+		t.Errorf("expected idle to be released")
+	}
+
+	// logind idle inhibitor locks hold back the idle stages as well.
+	locks = []logindInhibitor{{What: "idle", Who: "presenter", Why: "Presenting", Mode: "block"}}
+	l.idleDue(ctx)
+	if l.isIdle() || !l.held {
+		t.Fatalf("expected idle to be inhibited by logind")
+	}
+
+	if s := l.idleStatus().String(); s != "stage 0/1, inhibited by presenter (logind: Presenting)" {
+		t.Errorf("unexpected status: %s", s)
+	}
+
+	locks = nil
+	l.checkHeld(ctx)
+	l.idleDue(ctx)
+	if !l.isIdle() {
+		t.Fatalf("expected to enter idle stage")
+	}
+
+	// simulated activity leaves the idle stages.
+	l.simulateActivity(ctx)
+	l.devices[0].fader.wait()
+	if l.isIdle() {
+		t.Errorf("expected simulated activity to leave idle")
+	}
+
+	if v := sysfs.Brightness("backlight", "intel_backlight"); v != 500 {
+		t.Errorf("expected brightness 500, got %d", v)
+	}
+}
@@ -0,0 +1,126 @@
+package lis
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus"
+)
+
+const (
+	login1Dest         = "org.freedesktop.login1"
+	login1Path         = dbus.ObjectPath("/org/freedesktop/login1")
+	login1ManagerIface = "org.freedesktop.login1.Manager"
+	login1SessionIface = "org.freedesktop.login1.Session"
+	// the session of the caller, or the display session of the user if the
+	// caller is not part of a session e.g. when running as a systemd --user
+	// service.
+	login1SessionAuto = dbus.ObjectPath("/org/freedesktop/login1/session/auto")
+)
+
+// BrightnessWriter writes brightness values to backlight devices.
+type BrightnessWriter interface {
+	SetBrightness(subsystem, name string, value int) error
+}
+
+// LogindWriter writes brightness values through the logind
+// org.freedesktop.login1.Session.SetBrightness method. logind allows the
+// owner of a session to change the brightness without write access to
+// sysfs, making it possible to run lis unprivileged.
+type LogindWriter struct {
+	session dbus.BusObject
+}
+
+// NewLogindWriter initializes a new LogindWriter on the system bus.
+func NewLogindWriter() (*LogindWriter, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %s", err)
+	}
+
+	return &LogindWriter{
+		session: conn.Object(login1Dest, login1SessionAuto),
+	}, nil
+}
+
+// SetBrightness sets the brightness of the device name in subsystem.
+func (w *LogindWriter) SetBrightness(subsystem, name string, value int) error {
+	call := w.session.Call(login1SessionIface+".SetBrightness", 0, subsystem, name, uint32(value))
+	if call.Err != nil {
+		return fmt.Errorf("logind: failed to set brightness of %s: %s", name, call.Err)
+	}
+
+	return nil
+}
+
+// logindLockSession asks logind to lock the session. The desktop
+// environment or screen locker listening for the Lock signal of the session
+// does the actual locking.
+func logindLockSession() error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to system bus: %s", err)
+	}
+
+	call := conn.Object(login1Dest, login1SessionAuto).Call(login1SessionIface+".Lock", 0)
+	if call.Err != nil {
+		return fmt.Errorf("logind: failed to lock session: %s", call.Err)
+	}
+
+	return nil
+}
+
+// logindSuspend suspends the system through logind.
+func logindSuspend() error {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to system bus: %s", err)
+	}
+
+	call := conn.Object(login1Dest, login1Path).Call(login1ManagerIface+".Suspend", 0, false)
+	if call.Err != nil {
+		return fmt.Errorf("logind: failed to suspend: %s", call.Err)
+	}
+
+	return nil
+}
+
+// logindInhibitor is an inhibitor lock as returned by ListInhibitors.
+type logindInhibitor struct {
+	What string
+	Who  string
+	Why  string
+	Mode string
+	UID  uint32
+	PID  uint32
+}
+
+// logindIdleInhibitors returns the logind inhibitor locks blocking idle.
+func logindIdleInhibitors() ([]logindInhibitor, error) {
+	conn, err := dbus.SystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system bus: %s", err)
+	}
+
+	var locks []logindInhibitor
+	err = conn.Object(login1Dest, login1Path).Call(login1ManagerIface+".ListInhibitors", 0).Store(&locks)
+	if err != nil {
+		return nil, fmt.Errorf("logind: failed to list inhibitors: %s", err)
+	}
+
+	idle := locks[:0]
+	for _, lock := range locks {
+		if lock.Mode != "block" {
+			continue
+		}
+
+		for _, what := range strings.Split(lock.What, ":") {
+			if what == "idle" {
+				idle = append(idle, lock)
+				break
+			}
+		}
+	}
+
+	return idle, nil
+}
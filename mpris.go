@@ -0,0 +1,274 @@
+package lis
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus"
+)
+
+const (
+	// media players own a name prefixed with mprisPrefix, e.g.
+	// org.mpris.MediaPlayer2.mpv.
+	mprisPrefix      = "org.mpris.MediaPlayer2."
+	mprisPath        = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	mprisPlayerIface = "org.mpris.MediaPlayer2.Player"
+	mprisPlaying     = "Playing"
+	// inhibitor source of media players.
+	inhibitMPRIS = "mpris"
+
+	dbusPropertiesChanged = dbusPropertiesIface + ".PropertiesChanged"
+)
+
+// mprisPlayer is a media player on the session bus.
+type mprisPlayer struct {
+	name      string // player name e.g. mpv or firefox.instance_1_42
+	owner     string // unique name of the player connection
+	inhibitor uint32 // id of the inhibitor held while playing, 0 if none
+}
+
+// MPRIS inhibits idle while a media player implementing the MPRIS
+// interface is playing.
+type MPRIS struct {
+	conn       *dbus.Conn
+	inhibitors *inhibitors
+	allow      []string // players allowed to inhibit idle, all if empty
+	deny       []string // players never inhibiting idle
+	mu         sync.Mutex
+	players    map[string]*mprisPlayer // players by bus name
+	signals    chan *dbus.Signal
+	done       chan struct{}
+}
+
+// NewMPRIS watches the media players on conn. Players matching allow and
+// not matching deny inhibit idle while playing. Players are matched by
+// name, such that 'firefox' matches 'firefox.instance_1_42'.
+func NewMPRIS(conn *dbus.Conn, inhibitors *inhibitors, allow, deny []string) (*MPRIS, error) {
+	m := &MPRIS{
+		conn:       conn,
+		inhibitors: inhibitors,
+		allow:      allow,
+		deny:       deny,
+		players:    make(map[string]*mprisPlayer),
+		signals:    make(chan *dbus.Signal, 10),
+		done:       make(chan struct{}),
+	}
+
+	rules := []string{
+		fmt.Sprintf("type='signal',sender='%s',interface='%s',member='NameOwnerChanged',arg0namespace='%s'",
+			dbusName, dbusIface, strings.TrimSuffix(mprisPrefix, ".")),
+		fmt.Sprintf("type='signal',interface='%s',member='PropertiesChanged',path='%s',arg0='%s'",
+			dbusPropertiesIface, mprisPath, mprisPlayerIface),
+	}
+	for _, rule := range rules {
+		call := conn.BusObject().Call(dbusIface+".AddMatch", 0, rule)
+		if call.Err != nil {
+			return nil, fmt.Errorf("mpris: failed to watch players: %s", call.Err)
+		}
+	}
+	conn.Signal(m.signals)
+
+	var names []string
+	err := conn.BusObject().Call(dbusIface+".ListNames", 0).Store(&names)
+	if err != nil {
+		m.Close()
+		return nil, fmt.Errorf("mpris: failed to list players: %s", err)
+	}
+
+	for _, name := range names {
+		if !strings.HasPrefix(name, mprisPrefix) {
+			continue
+		}
+
+		var owner string
+		err := conn.BusObject().Call(dbusIface+".GetNameOwner", 0, name).Store(&owner)
+		if err != nil {
+			// the player left in the meantime.
+			continue
+		}
+
+		m.addPlayer(name, owner)
+	}
+
+	return m, nil
+}
+
+// Run tracks the players and their playback status until closed.
+func (m *MPRIS) Run() {
+	for {
+		var signal *dbus.Signal
+		select {
+		case signal = <-m.signals:
+		case <-m.done:
+			return
+		}
+
+		if signal == nil {
+			continue
+		}
+
+		switch signal.Name {
+		case dbusIface + ".NameOwnerChanged":
+			if len(signal.Body) != 3 {
+				continue
+			}
+
+			name, _ := signal.Body[0].(string)
+			newOwner, _ := signal.Body[2].(string)
+			if !strings.HasPrefix(name, mprisPrefix) {
+				continue
+			}
+
+			m.removePlayer(name)
+			if newOwner != "" {
+				m.addPlayer(name, newOwner)
+			}
+		case dbusPropertiesChanged:
+			if signal.Path != mprisPath || len(signal.Body) != 3 {
+				continue
+			}
+
+			if iface, _ := signal.Body[0].(string); iface != mprisPlayerIface {
+				continue
+			}
+
+			if !playbackChanged(signal.Body[1], signal.Body[2]) {
+				continue
+			}
+
+			m.mu.Lock()
+			for name, p := range m.players {
+				if p.owner == signal.Sender {
+					m.update(name, p)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+// Close stops watching the players and releases their inhibitors.
+func (m *MPRIS) Close() {
+	m.conn.RemoveSignal(m.signals)
+	close(m.done)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range m.players {
+		m.inhibitors.removeOwner(inhibitMPRIS, name)
+	}
+}
+
+// addPlayer starts tracking the player owning name.
+func (m *MPRIS) addPlayer(name, owner string) {
+	p := &mprisPlayer{name: strings.TrimPrefix(name, mprisPrefix), owner: owner}
+	if !mprisAllowed(p.name, m.allow, m.deny) {
+		slog.Info(fmt.Sprintf("Ignoring media player %s", p.name))
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.players[name] = p
+	m.update(name, p)
+}
+
+// removePlayer stops tracking the player owning name.
+func (m *MPRIS) removePlayer(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.players[name]; !ok {
+		return
+	}
+
+	delete(m.players, name)
+	if m.inhibitors.removeOwner(inhibitMPRIS, name) > 0 {
+		slog.Info(fmt.Sprintf("Media player %s left", strings.TrimPrefix(name, mprisPrefix)))
+	}
+}
+
+// update queries the playback status of the player and holds an inhibitor
+// while it is playing. The status is queried rather than taken from the
+// signal, as signals may be delivered out of order. Must be called with
+// m.mu held.
+func (m *MPRIS) update(name string, p *mprisPlayer) {
+	v, err := m.conn.Object(p.owner, mprisPath).GetProperty(mprisPlayerIface + ".PlaybackStatus")
+	if err != nil {
+		slog.Error(fmt.Sprintf("Failed to get playback status of %s: %s", p.name, err))
+		return
+	}
+
+	status, _ := v.Value().(string)
+	playing := status == mprisPlaying
+	switch {
+	case playing && p.inhibitor == 0:
+		p.inhibitor = m.inhibitors.add(inhibitMPRIS, name, p.name, "playing")
+		slog.Info(fmt.Sprintf("Idle inhibited by %s: playing", p.name))
+	case !playing && p.inhibitor != 0:
+		m.inhibitors.remove(inhibitMPRIS, p.inhibitor)
+		p.inhibitor = 0
+		slog.Info(fmt.Sprintf("Media player %s stopped playing", p.name))
+	}
+}
+
+// playbackChanged returns true if a PropertiesChanged signal of a player
+// includes the PlaybackStatus property.
+func playbackChanged(changed, invalidated interface{}) bool {
+	if props, ok := changed.(map[string]dbus.Variant); ok {
+		if _, ok := props["PlaybackStatus"]; ok {
+			return true
+		}
+	}
+
+	if props, ok := invalidated.([]string); ok {
+		for _, prop := range props {
+			if prop == "PlaybackStatus" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// mprisAllowed returns true if the player name may inhibit idle.
+func mprisAllowed(name string, allow, deny []string) bool {
+	if mprisMatch(name, deny) {
+		return false
+	}
+
+	return len(allow) == 0 || mprisMatch(name, allow)
+}
+
+// mprisMatch returns true if the player name matches one of the names. A
+// name matches the player itself and its instances, e.g. 'firefox' matches
+// 'firefox.instance_1_42'.
+func mprisMatch(name string, names []string) bool {
+	for _, n := range names {
+		if name == n || strings.HasPrefix(name, n+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// startMPRIS watches the media players on the session bus.
+func (l *Lis) startMPRIS() (*MPRIS, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %s", err)
+	}
+
+	m, err := NewMPRIS(conn, l.inhibitors, l.mpris.Allow, l.mpris.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	go m.Run()
+
+	return m, nil
+}
@@ -0,0 +1,145 @@
+package lis
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus"
+)
+
+// fakePlayer implements the PlaybackStatus property of a media player.
+type fakePlayer struct {
+	conn   *dbus.Conn
+	mu     sync.Mutex
+	status string
+}
+
+// startPlayer connects a media player owning org.mpris.MediaPlayer2.<name>
+// to the bus at address.
+func startPlayer(t *testing.T, address, name, status string) *fakePlayer {
+	t.Helper()
+
+	p := &fakePlayer{conn: dialBus(t, address), status: status}
+	err := p.conn.Export(p, mprisPath, dbusPropertiesIface)
+	if err != nil {
+		t.Fatalf("failed to export player: %s", err)
+	}
+
+	_, err = p.conn.RequestName(mprisPrefix+name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		t.Fatalf("failed to request name: %s", err)
+	}
+
+	return p
+}
+
+// Get implements org.freedesktop.DBus.Properties.Get.
+func (p *fakePlayer) Get(iface, prop string) (dbus.Variant, *dbus.Error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return dbus.MakeVariant(p.status), nil
+}
+
+// set sets the playback status and signals the change.
+func (p *fakePlayer) set(t *testing.T, status string) {
+	t.Helper()
+
+	p.mu.Lock()
+	p.status = status
+	p.mu.Unlock()
+
+	err := p.conn.Emit(mprisPath, dbusPropertiesChanged, mprisPlayerIface,
+		map[string]dbus.Variant{"PlaybackStatus": dbus.MakeVariant(status)}, []string{})
+	if err != nil {
+		t.Fatalf("failed to emit PropertiesChanged: %s", err)
+	}
+}
+
+func TestMPRIS(t *testing.T) {
+	address := startBus(t)
+
+	conn := dialBus(t, address)
+	defer conn.Close()
+
+	// players already playing are picked up.
+	mpv := startPlayer(t, address, "mpv", mprisPlaying)
+	defer mpv.conn.Close()
+
+	inhibitors := newInhibitors()
+	m, err := NewMPRIS(conn, inhibitors, nil, []string{"spotify"})
+	if err != nil {
+		t.Fatalf("failed to watch players: %s", err)
+	}
+	defer m.Close()
+	go m.Run()
+
+	// waitApps waits until the inhibitors are held by apps.
+	waitApps := func(apps ...string) {
+		t.Helper()
+		for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+			list := inhibitors.list()
+			held := make([]string, 0, len(list))
+			for _, inh := range list {
+				held = append(held, inh.app)
+			}
+
+			if len(held) == len(apps) {
+				match := true
+				for i := range apps {
+					match = match && held[i] == apps[i]
+				}
+				if match {
+					return
+				}
+			}
+
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("expected inhibitors %v, got %v", apps, held)
+			}
+		}
+	}
+
+	waitApps("mpv")
+
+	mpv.set(t, "Paused")
+	waitApps()
+
+	// denied players don't inhibit idle.
+	spotify := startPlayer(t, address, "spotify", mprisPlaying)
+	defer spotify.conn.Close()
+
+	firefox := startPlayer(t, address, "firefox.instance_1_42", "Stopped")
+	defer firefox.conn.Close()
+	firefox.set(t, mprisPlaying)
+	waitApps("firefox.instance_1_42")
+
+	mpv.set(t, mprisPlaying)
+	waitApps("firefox.instance_1_42", "mpv")
+
+	// inhibitors are released when the player leaves the bus.
+	firefox.conn.Close()
+	waitApps("mpv")
+}
+
+func TestMPRISAllowed(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		allow    []string
+		deny     []string
+		expected bool
+	}{
+		{"mpv", nil, nil, true},
+		{"mpv", []string{"mpv"}, nil, true},
+		{"firefox.instance_1_42", []string{"firefox"}, nil, true},
+		{"firefoxnightly", []string{"firefox"}, nil, false},
+		{"spotify", []string{"mpv"}, nil, false},
+		{"spotify", nil, []string{"spotify"}, false},
+		{"chromium.instance42", []string{"chromium"}, []string{"chromium.instance42"}, false},
+	} {
+		allowed := mprisAllowed(tc.name, tc.allow, tc.deny)
+		if allowed != tc.expected {
+			t.Errorf("%s (allow %v, deny %v): expected %t, got %t", tc.name, tc.allow, tc.deny, tc.expected, allowed)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package lis
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/godbus/dbus"
+)
+
+const (
+	screenSaverName  = "org.freedesktop.ScreenSaver"
+	screenSaverIface = "org.freedesktop.ScreenSaver"
+	// inhibitor source of the ScreenSaver service.
+	inhibitScreenSaver = "screensaver"
+
+	dbusName  = "org.freedesktop.DBus"
+	dbusPath  = dbus.ObjectPath("/org/freedesktop/DBus")
+	dbusIface = "org.freedesktop.DBus"
+)
+
+// applications use either path.
+var screenSaverPaths = []dbus.ObjectPath{
+	"/org/freedesktop/ScreenSaver",
+	"/ScreenSaver",
+}
+
+// ScreenSaver implements the org.freedesktop.ScreenSaver interface on the
+// session bus, used by video players and browsers to inhibit idle.
+// Inhibitors are released when the application disconnects from the bus.
+type ScreenSaver struct {
+	conn       *dbus.Conn
+	inhibitors *inhibitors
+	activity   func() // called on SimulateUserActivity
+	signals    chan *dbus.Signal
+	done       chan struct{}
+}
+
+// NewScreenSaver exports the ScreenSaver service on conn and requests the
+// org.freedesktop.ScreenSaver name.
+func NewScreenSaver(conn *dbus.Conn, inhibitors *inhibitors, activity func()) (*ScreenSaver, error) {
+	s := &ScreenSaver{
+		conn:       conn,
+		inhibitors: inhibitors,
+		activity:   activity,
+		signals:    make(chan *dbus.Signal, 10),
+		done:       make(chan struct{}),
+	}
+
+	for _, path := range screenSaverPaths {
+		err := conn.Export(s, path, screenSaverIface)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// track peers to release the inhibitors of disconnected applications.
+	rule := fmt.Sprintf("type='signal',sender='%s',interface='%s',member='NameOwnerChanged'", dbusName, dbusIface)
+	call := conn.BusObject().Call(dbusIface+".AddMatch", 0, rule)
+	if call.Err != nil {
+		return nil, fmt.Errorf("screensaver: failed to watch peers: %s", call.Err)
+	}
+	conn.Signal(s.signals)
+
+	reply, err := conn.RequestName(screenSaverName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("screensaver: failed to request name: %s", err)
+	}
+
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		s.Close()
+		return nil, fmt.Errorf("screensaver: %s is already owned", screenSaverName)
+	}
+
+	slog.Info(fmt.Sprintf("Providing %s on the session bus", screenSaverName))
+
+	return s, nil
+}
+
+// Run releases the inhibitors of peers leaving the bus until the service is
+// closed.
+func (s *ScreenSaver) Run() {
+	for {
+		var signal *dbus.Signal
+		select {
+		case signal = <-s.signals:
+		case <-s.done:
+			return
+		}
+
+		if signal == nil || signal.Name != dbusIface+".NameOwnerChanged" || len(signal.Body) != 3 {
+			continue
+		}
+
+		name, _ := signal.Body[0].(string)
+		newOwner, _ := signal.Body[2].(string)
+		if newOwner != "" {
+			continue
+		}
+
+		n := s.inhibitors.removeOwner(inhibitScreenSaver, name)
+		if n > 0 {
+			slog.Info(fmt.Sprintf("Released %d inhibitor(s) of disconnected peer %s", n, name))
+		}
+	}
+}
+
+// Close releases the name and stops tracking peers.
+func (s *ScreenSaver) Close() error {
+	s.conn.RemoveSignal(s.signals)
+	close(s.done)
+	_, err := s.conn.ReleaseName(screenSaverName)
+	return err
+}
+
+// Inhibit inhibits idle until UnInhibit is called with the returned cookie
+// or the caller disconnects.
+func (s *ScreenSaver) Inhibit(sender dbus.Sender, app, reason string) (uint32, *dbus.Error) {
+	cookie := s.inhibitors.add(inhibitScreenSaver, string(sender), app, reason)
+	slog.Info(fmt.Sprintf("Idle inhibited by %s: %s", app, reason))
+	return cookie, nil
+}
+
+// UnInhibit releases the inhibitor identified by cookie.
+func (s *ScreenSaver) UnInhibit(cookie uint32) *dbus.Error {
+	if !s.inhibitors.remove(inhibitScreenSaver, cookie) {
+		return dbus.NewError(screenSaverIface+".Error.InvalidCookie", []interface{}{fmt.Sprintf("invalid cookie: %d", cookie)})
+	}
+
+	slog.Info(fmt.Sprintf("Released inhibitor %d", cookie))
+	return nil
+}
+
+// SimulateUserActivity resets the idle time as if the user was active.
+func (s *ScreenSaver) SimulateUserActivity() *dbus.Error {
+	s.activity()
+	return nil
+}
+
+// startScreenSaver provides the ScreenSaver service on the session bus.
+func (l *Lis) startScreenSaver() (*ScreenSaver, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to session bus: %s", err)
+	}
+
+	s, err := NewScreenSaver(conn, l.inhibitors, l.simulateUserActivity)
+	if err != nil {
+		return nil, err
+	}
+
+	go s.Run()
+
+	return s, nil
+}
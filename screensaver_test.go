@@ -0,0 +1,161 @@
+package lis
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus"
+)
+
+const testBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <auth>EXTERNAL</auth>
+  <policy context="default">
+    <allow send_destination="*" eavesdrop="true"/>
+    <allow eavesdrop="true"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startBus starts a private dbus-daemon and returns its address. The test
+// is skipped if dbus-daemon is not installed.
+func startBus(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+
+	dir := t.TempDir()
+	config := path.Join(dir, "bus.conf")
+	err := ioutil.WriteFile(config, []byte(strings.Replace(testBusConfig, "%s", path.Join(dir, "bus"), 1)), 0644)
+	if err != nil {
+		t.Fatalf("failed to write bus config: %s", err)
+	}
+
+	cmd := exec.Command("dbus-daemon", "--config-file="+config, "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("failed to start dbus-daemon: %s", err)
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatalf("failed to start dbus-daemon: %s", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read bus address: %s", err)
+	}
+
+	return strings.TrimSpace(address)
+}
+
+// dialBus connects to the bus at address.
+func dialBus(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+
+	conn, err := dbus.Dial(address)
+	if err == nil {
+		err = conn.Auth(nil)
+	}
+	if err == nil {
+		err = conn.Hello()
+	}
+	if err != nil {
+		t.Fatalf("failed to connect to bus: %s", err)
+	}
+
+	return conn
+}
+
+func TestScreenSaver(t *testing.T) {
+	address := startBus(t)
+
+	conn := dialBus(t, address)
+	defer conn.Close()
+
+	inhibitors := newInhibitors()
+	activity := make(chan struct{}, 1)
+	s, err := NewScreenSaver(conn, inhibitors, func() { activity <- struct{}{} })
+	if err != nil {
+		t.Fatalf("failed to start ScreenSaver: %s", err)
+	}
+	defer s.Close()
+	go s.Run()
+
+	client := dialBus(t, address)
+	defer client.Close()
+
+	obj := client.Object(screenSaverName, screenSaverPaths[0])
+
+	var cookie uint32
+	err = obj.Call(screenSaverIface+".Inhibit", 0, "mpv", "Playing video").Store(&cookie)
+	if err != nil {
+		t.Fatalf("Inhibit failed: %s", err)
+	}
+
+	list := inhibitors.list()
+	if len(list) != 1 || list[0].app != "mpv" || list[0].owner != client.Names()[0] {
+		t.Fatalf("unexpected inhibitors: %v", list)
+	}
+
+	err = obj.Call(screenSaverIface+".UnInhibit", 0, cookie).Err
+	if err != nil {
+		t.Fatalf("UnInhibit failed: %s", err)
+	}
+
+	if list := inhibitors.list(); len(list) != 0 {
+		t.Errorf("expected no inhibitors, got %v", list)
+	}
+
+	err = obj.Call(screenSaverIface+".UnInhibit", 0, cookie).Err
+	if err == nil {
+		t.Errorf("expected error for released cookie")
+	}
+
+	err = obj.Call(screenSaverIface+".SimulateUserActivity", 0).Err
+	if err != nil {
+		t.Fatalf("SimulateUserActivity failed: %s", err)
+	}
+
+	select {
+	case <-activity:
+	case <-time.After(5 * time.Second):
+		t.Errorf("activity was not simulated")
+	}
+
+	// inhibitors are released when the client disconnects.
+	other := dialBus(t, address)
+	err = other.Object(screenSaverName, screenSaverPaths[1]).Call(screenSaverIface+".Inhibit", 0, "firefox", "Playing video").Store(&cookie)
+	if err != nil {
+		t.Fatalf("Inhibit failed: %s", err)
+	}
+	other.Close()
+
+	for start := time.Now(); len(inhibitors.list()) > 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("inhibitor of disconnected client was not released")
+		}
+	}
+
+	// the name can only be owned once.
+	_, err = NewScreenSaver(client, newInhibitors(), func() {})
+	if err == nil {
+		t.Errorf("expected error when the name is already owned")
+	}
+}
@@ -0,0 +1,284 @@
+package lis
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// StageDim dims the backlights.
+	StageDim = "dim"
+	// StageScreenOff turns off the display.
+	StageScreenOff = "screenoff"
+	// StageLock locks the session.
+	StageLock = "lock"
+	// StageSuspend suspends the system through logind.
+	StageSuspend = "suspend"
+)
+
+var stageActions = []string{StageDim, StageScreenOff, StageLock, StageSuspend}
+
+// stage is an idle stage of the lis state machine.
+type stage struct {
+	action  string
+	timeout time.Duration // idle time before the stage is entered
+	level   *Level        // level to dim to, nil for the device default
+	command []string      // locker command, empty to lock the logind session
+}
+
+// newStages converts the configured idle stages. Without configured stages
+// the backlights are dimmed after idleTime.
+func newStages(config []IdleStage, idleTime uint) []stage {
+	if len(config) == 0 {
+		config = []IdleStage{{Timeout: idleTime, Action: StageDim}}
+	}
+
+	stages := make([]stage, 0, len(config))
+	for _, s := range config {
+		stages = append(stages, stage{
+			action:  s.Action,
+			timeout: time.Duration(s.Timeout) * time.Millisecond,
+			level:   s.Level,
+			command: s.Command,
+		})
+	}
+
+	return stages
+}
+
+// idleStatus describes the position of lis in the idle stages.
+type idleStatus struct {
+	stage      int           // number of entered stages
+	stages     int           // total number of stages
+	next       string        // action of the next stage
+	until      time.Duration // time until the next stage, if idle
+	held       bool          // the next stage is held back by an inhibitor
+	inhibitors []string      // inhibitors holding back the next stage
+}
+
+func (s idleStatus) String() string {
+	status := fmt.Sprintf("stage %d/%d", s.stage, s.stages)
+	if len(s.inhibitors) > 0 {
+		status += ", inhibited by " + strings.Join(s.inhibitors, ", ")
+	} else if s.held {
+		status += ", inhibited"
+	} else if s.stage > 0 && s.stage < s.stages {
+		status += fmt.Sprintf(", %ds until %s", int(math.Ceil(s.until.Seconds())), s.next)
+	}
+	return status
+}
+
+// isIdle returns true if at least one idle stage has been entered.
+func (l *Lis) isIdle() bool {
+	return l.stage > 0
+}
+
+// idleStatus returns the current position in the idle stages.
+func (l *Lis) idleStatus() idleStatus {
+	status := idleStatus{stage: l.stage, stages: len(l.stages), held: l.held}
+	for _, inh := range l.inhibitors.list() {
+		status.inhibitors = append(status.inhibitors, inh.String())
+	}
+	// the stages may be held by a fullscreen window or logind.
+	if l.held && len(status.inhibitors) == 0 {
+		if by := l.inhibitedBy(); by != "" {
+			status.inhibitors = append(status.inhibitors, by)
+		}
+	}
+	if l.stage < len(l.stages) && !l.held {
+		next := l.stages[l.stage]
+		status.next = next.action
+		if l.isIdle() {
+			current := l.stages[l.stage-1]
+			status.until = next.timeout - current.timeout - time.Since(l.stageAt)
+			if status.until < 0 {
+				status.until = 0
+			}
+		}
+	}
+	return status
+}
+
+// stageTimeout returns a channel which fires when the next idle stage is
+// due, or nil if no stage is pending.
+func (l *Lis) stageTimeout() <-chan time.Time {
+	if l.stageTimer == nil {
+		return nil
+	}
+	return l.stageTimer.C
+}
+
+// idleDue enters the next idle stage unless it is held back by an
+// inhibitor.
+func (l *Lis) idleDue(ctx context.Context) {
+	l.stageTimer = nil
+
+	if by := l.inhibitedBy(); by != "" {
+		if !l.held {
+			slog.Info(fmt.Sprintf("Idle stage %d/%d inhibited by %s", l.stage+1, len(l.stages), by))
+		}
+		l.hold()
+		return
+	}
+
+	l.nextStage(ctx)
+}
+
+// nextStage enters the next idle stage. The first stage is entered when
+// the idle source reports the user idle, the following stages are timed
+// relative to it.
+func (l *Lis) nextStage(ctx context.Context) {
+	s := l.stages[l.stage]
+	l.stage++
+	l.stageAt = time.Now()
+	l.stageTimer = nil
+
+	slog.Info(fmt.Sprintf("Entering idle stage %d/%d: %s", l.stage, len(l.stages), s.action))
+
+	err := l.runStage(s)
+	if err != nil {
+		slog.Error(fmt.Sprintf("Idle stage %s failed: %s", s.action, err))
+	}
+
+	if l.stage == 1 {
+		// start listening for input to leave the idle stages
+		l.inputListener(ctx)
+	}
+
+	if l.stage < len(l.stages) {
+		l.stageTimer = time.NewTimer(l.stages[l.stage].timeout - s.timeout)
+	}
+}
+
+// runStage runs the action of an idle stage.
+func (l *Lis) runStage(s stage) error {
+	switch s.action {
+	case StageDim:
+		l.dim(s.level)
+	case StageScreenOff:
+		l.screenOff()
+	case StageLock:
+		return l.lock(s.command)
+	case StageSuspend:
+		return logindSuspend()
+	}
+	return nil
+}
+
+// resume leaves all entered idle stages and restores the brightness chosen
+// by the user.
+func (l *Lis) resume() {
+	if l.stageTimer != nil {
+		l.stageTimer.Stop()
+		l.stageTimer = nil
+	}
+	l.unhold()
+
+	restore := false
+	for _, s := range l.stages[:l.stage] {
+		switch s.action {
+		case StageDim:
+			restore = true
+		case StageScreenOff:
+			restore = true
+			if !l.backlightOff {
+				err := l.setDPMS(true)
+				if err != nil {
+					slog.Error(fmt.Sprintf("Failed to turn on display: %s", err))
+				}
+			}
+		}
+	}
+
+	if restore {
+		l.unDim()
+	}
+	l.backlightOff = false
+
+	if l.isIdle() {
+		slog.Info(fmt.Sprintf("Leaving idle stage %d/%d", l.stage, len(l.stages)))
+	}
+	l.stage = 0
+}
+
+// idleLevel returns the raw brightness value of d in the current idle
+// stage.
+func (l *Lis) idleLevel(d *device) int {
+	value := int(d.current)
+	for _, s := range l.stages[:l.stage] {
+		switch s.action {
+		case StageDim:
+			value = d.dimLevel(s.level)
+		case StageScreenOff:
+			if l.backlightOff {
+				value = 0
+			}
+		}
+	}
+	return value
+}
+
+// screenOff turns off the displays through DPMS. If DPMS fails the display
+// backlights are turned off instead.
+func (l *Lis) screenOff() {
+	err := l.setDPMS(false)
+	if err == nil {
+		return
+	}
+
+	slog.Error(fmt.Sprintf("Failed to turn off display, turning off backlight instead: %s", err))
+	l.backlightOff = true
+	for _, d := range l.devices {
+		if d.bound && d.class == classPanel {
+			slog.Info(fmt.Sprintf("Turning off %s", d.Name))
+			d.fadeTo(0, l.errors)
+		}
+	}
+}
+
+// lock locks the session by running command, or through logind if no
+// command is configured. A command consisting of a single string is run by
+// the shell. The locker is not started again while already running.
+func (l *Lis) lock(command []string) error {
+	if len(command) == 0 {
+		return logindLockSession()
+	}
+
+	if l.locker != nil {
+		select {
+		case <-l.locker:
+		default:
+			slog.Info("Locker is still running")
+			return nil
+		}
+	}
+
+	var cmd *exec.Cmd
+	if len(command) == 1 {
+		cmd = exec.Command("/bin/sh", "-c", command[0])
+	} else {
+		cmd = exec.Command(command[0], command[1:]...)
+	}
+
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	l.locker = done
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			slog.Error(fmt.Sprintf("Locker exited: %s", err))
+		}
+		close(done)
+	}()
+
+	return nil
+}
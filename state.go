@@ -1,43 +1,98 @@
 package lis
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// legacyState is the key used for the single value stored by state files
+// written before lis supported multiple devices.
+const legacyState = ""
+
 // StateFile is a path to the lis state file.
 type StateFile string
 
-// Read value from stateFile.
-func (s StateFile) Read() (uint16, error) {
-	file, err := os.Open(string(s))
+// State maps backlight device names to brightness values.
+type State map[string]uint16
+
+// Read state from stateFile.
+//
+// The state file stores one line per device in the format '<name> <value>'.
+// State files in the old binary format, storing a single value, are read
+// into the legacyState key.
+func (s StateFile) Read() (State, error) {
+	data, err := ioutil.ReadFile(string(s))
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer file.Close()
 
-	data := make([]byte, 2)
+	state := make(State)
 
-	_, err = file.Read(data)
-	if err != nil {
-		return 0, err
+	if len(data) == 2 {
+		state[legacyState] = binary.BigEndian.Uint16(data)
+		return state, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid state line: %s", line)
+		}
+
+		v, err := strconv.ParseUint(fields[1], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state value for %s: %s", fields[0], err)
+		}
+
+		state[fields[0]] = uint16(v)
 	}
 
-	return binary.BigEndian.Uint16(data), nil
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return state, nil
 }
 
-// Write value to stateFile.
-func (s StateFile) Write(value uint16) error {
-	file, err := os.OpenFile(string(s), os.O_CREATE|os.O_WRONLY, 0644)
+// Write state to stateFile.
+func (s StateFile) Write(state State) error {
+	err := os.MkdirAll(path.Dir(string(s)), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(string(s), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	data := make([]byte, 2)
-	binary.BigEndian.PutUint16(data, value)
+	names := make([]string, 0, len(state))
+	for name := range state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s %d\n", name, state[name])
+	}
 
-	_, err = file.Write(data)
+	_, err = file.Write(buf.Bytes())
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,8 @@
 package lis
 
 import (
+	"encoding/binary"
+	"io/ioutil"
 	"os"
 	"testing"
 )
@@ -8,7 +10,7 @@ import (
 func TestWrite(t *testing.T) {
 	state := StateFile("test")
 
-	err := state.Write(100)
+	err := state.Write(State{"intel_backlight": 100, "acpi_video0": 7})
 	if err != nil {
 		t.Errorf("failed to write state: %s", err.Error())
 	}
@@ -22,8 +24,12 @@ func TestRead(t *testing.T) {
 		t.Errorf("should not cause error")
 	}
 
-	if v != 100 {
-		t.Errorf("should be %d, was %d", 100, v)
+	if v["intel_backlight"] != 100 {
+		t.Errorf("should be %d, was %d", 100, v["intel_backlight"])
+	}
+
+	if v["acpi_video0"] != 7 {
+		t.Errorf("should be %d, was %d", 7, v["acpi_video0"])
 	}
 
 	err = os.Remove("test")
@@ -31,3 +37,23 @@ func TestRead(t *testing.T) {
 		t.Errorf("error when removing test state file")
 	}
 }
+
+func TestReadLegacy(t *testing.T) {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, 100)
+
+	err := ioutil.WriteFile("test_legacy", data, 0644)
+	if err != nil {
+		t.Fatalf("failed to write legacy state: %s", err)
+	}
+	defer os.Remove("test_legacy")
+
+	v, err := StateFile("test_legacy").Read()
+	if err != nil {
+		t.Errorf("should not cause error: %s", err)
+	}
+
+	if v[legacyState] != 100 {
+		t.Errorf("should be %d, was %d", 100, v[legacyState])
+	}
+}
@@ -0,0 +1,139 @@
+package lis
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+)
+
+const (
+	// uevent actions.
+	ueventAdd    = "add"
+	ueventRemove = "remove"
+	ueventChange = "change"
+
+	// netlink multicast group of kernel uevents.
+	ueventKernelGroup = 1
+	ueventBufSize     = 16 * 1024
+)
+
+// UEvent is a kernel uevent.
+type UEvent struct {
+	Action    string
+	DevPath   string
+	Subsystem string
+	Env       map[string]string
+}
+
+// Name returns the name of the device of the event, e.g. 'intel_backlight'.
+func (e UEvent) Name() string {
+	return path.Base(e.DevPath)
+}
+
+// ParseUEvent parses a kernel uevent message. The message is a header in
+// the format '<action>@<devpath>' followed by 'KEY=value' pairs, all NUL
+// terminated.
+func ParseUEvent(msg []byte) (UEvent, error) {
+	fields := bytes.Split(msg, []byte{0})
+	header := strings.SplitN(string(fields[0]), "@", 2)
+	if len(header) != 2 {
+		return UEvent{}, fmt.Errorf("invalid uevent header: %s", fields[0])
+	}
+
+	event := UEvent{
+		Action:  header[0],
+		DevPath: header[1],
+		Env:     make(map[string]string),
+	}
+
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(string(f), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		event.Env[kv[0]] = kv[1]
+	}
+
+	event.Subsystem = event.Env["SUBSYSTEM"]
+
+	return event, nil
+}
+
+// UEventListener listens for kernel uevents on a netlink socket.
+type UEventListener struct {
+	file *os.File
+}
+
+// NewUEventListener initializes a new UEventListener.
+func NewUEventListener() (*UEventListener, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uevent socket: %s", err)
+	}
+
+	err = syscall.SetNonblock(fd, true)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: ueventKernelGroup,
+	})
+	if err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("failed to bind uevent socket: %s", err)
+	}
+
+	return &UEventListener{
+		file: os.NewFile(uintptr(fd), "uevent"),
+	}, nil
+}
+
+// Run reads uevents and sends the events handled by lis to events.
+func (u *UEventListener) Run(events chan<- UEvent, errCh chan<- error) {
+	buf := make([]byte, ueventBufSize)
+	for {
+		n, err := u.file.Read(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				return
+			}
+			errCh <- fmt.Errorf("failed to read uevent: %s", err)
+			continue
+		}
+
+		event, err := ParseUEvent(buf[:n])
+		if err != nil {
+			errCh <- err
+			continue
+		}
+
+		if handledUEvent(event) {
+			events <- event
+		}
+	}
+}
+
+// handledUEvent returns true for the uevents handled by lis. The leds class
+// doesn't report brightness changes, only its devices being added and
+// removed are handled.
+func handledUEvent(event UEvent) bool {
+	switch event.Subsystem {
+	case subsystemBacklight:
+		return true
+	case subsystemLeds:
+		return event.Action != ueventChange
+	}
+	return false
+}
+
+// Close closes the netlink socket.
+func (u *UEventListener) Close() error {
+	return u.file.Close()
+}
@@ -0,0 +1,58 @@
+package lis
+
+import (
+	"testing"
+)
+
+func TestParseUEvent(t *testing.T) {
+	msg := []byte("change@/devices/pci0000:00/0000:00:02.0/drm/card0/card0-eDP-1/intel_backlight\x00" +
+		"ACTION=change\x00" +
+		"DEVPATH=/devices/pci0000:00/0000:00:02.0/drm/card0/card0-eDP-1/intel_backlight\x00" +
+		"SUBSYSTEM=backlight\x00" +
+		"SOURCE=sysfs\x00" +
+		"SEQNUM=4321\x00")
+
+	event, err := ParseUEvent(msg)
+	if err != nil {
+		t.Fatalf("should not cause error: %s", err)
+	}
+
+	if event.Action != ueventChange {
+		t.Errorf("expected action %s, got %s", ueventChange, event.Action)
+	}
+
+	if event.Subsystem != subsystemBacklight {
+		t.Errorf("expected subsystem %s, got %s", subsystemBacklight, event.Subsystem)
+	}
+
+	if event.Name() != "intel_backlight" {
+		t.Errorf("expected name intel_backlight, got %s", event.Name())
+	}
+
+	if event.Env["SOURCE"] != "sysfs" {
+		t.Errorf("expected SOURCE=sysfs, got %s", event.Env["SOURCE"])
+	}
+
+	_, err = ParseUEvent([]byte("libudev\x00"))
+	if err == nil {
+		t.Errorf("expected error for invalid header")
+	}
+}
+
+func TestHandledUEvent(t *testing.T) {
+	for _, tc := range []struct {
+		event   UEvent
+		handled bool
+	}{
+		{UEvent{Action: ueventChange, Subsystem: subsystemBacklight}, true},
+		{UEvent{Action: ueventAdd, Subsystem: subsystemBacklight}, true},
+		{UEvent{Action: ueventAdd, Subsystem: subsystemLeds}, true},
+		{UEvent{Action: ueventRemove, Subsystem: subsystemLeds}, true},
+		{UEvent{Action: ueventChange, Subsystem: subsystemLeds}, false},
+		{UEvent{Action: ueventChange, Subsystem: "power_supply"}, false},
+	} {
+		if handled := handledUEvent(tc.event); handled != tc.handled {
+			t.Errorf("%s %s: expected handled %t, got %t", tc.event.Action, tc.event.Subsystem, tc.handled, handled)
+		}
+	}
+}
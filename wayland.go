@@ -0,0 +1,249 @@
+package lis
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sync"
+)
+
+// Minimal Wayland client implementing just enough of the wire protocol to
+// bind globals and exchange messages without fd passing.
+
+const (
+	wlDisplayID = 1
+
+	// wl_display requests and events.
+	wlDisplaySync        = 0
+	wlDisplayGetRegistry = 1
+	wlDisplayError       = 0
+
+	// wl_registry requests and events.
+	wlRegistryBind   = 0
+	wlRegistryGlobal = 0
+
+	// wl_callback events.
+	wlCallbackDone = 0
+
+	wlHeaderSize = 8
+)
+
+// wlMessage is a request or event read from a Wayland connection.
+type wlMessage struct {
+	sender uint32
+	opcode uint16
+	args   []byte
+}
+
+// uint32 decodes the next uint, int, object or new_id argument.
+func (m *wlMessage) uint32() (uint32, error) {
+	if len(m.args) < 4 {
+		return 0, fmt.Errorf("wayland: message too short")
+	}
+
+	v := binary.NativeEndian.Uint32(m.args)
+	m.args = m.args[4:]
+	return v, nil
+}
+
+// string decodes the next string argument.
+func (m *wlMessage) string() (string, error) {
+	n, err := m.uint32()
+	if err != nil {
+		return "", err
+	}
+
+	padded := (int(n) + 3) &^ 3
+	if n == 0 || len(m.args) < padded {
+		return "", fmt.Errorf("wayland: invalid string argument")
+	}
+
+	s := string(m.args[:n-1])
+	m.args = m.args[padded:]
+	return s, nil
+}
+
+// wlConn is a connection to a Wayland compositor.
+type wlConn struct {
+	net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+	nextID uint32
+}
+
+func newWlConn(conn net.Conn) *wlConn {
+	return &wlConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+		nextID: wlDisplayID,
+	}
+}
+
+// wlSocketPath returns the path of the socket of the Wayland compositor
+// given by WAYLAND_DISPLAY.
+func wlSocketPath() (string, error) {
+	display := os.Getenv("WAYLAND_DISPLAY")
+	if display == "" {
+		display = "wayland-0"
+	}
+
+	if path.IsAbs(display) {
+		return display, nil
+	}
+
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		return "", fmt.Errorf("wayland: XDG_RUNTIME_DIR is not set")
+	}
+
+	return path.Join(dir, display), nil
+}
+
+// dialWayland connects to the compositor of the session.
+func dialWayland() (*wlConn, error) {
+	socket, err := wlSocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("wayland: failed to connect to compositor: %s", err)
+	}
+
+	return newWlConn(conn), nil
+}
+
+// newID allocates a new client object id.
+func (c *wlConn) newID() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	return c.nextID
+}
+
+// send sends a message to object. Arguments must be of type uint32 or
+// string.
+func (c *wlConn) send(object uint32, opcode uint16, args ...interface{}) error {
+	buf := make([]byte, wlHeaderSize, 32)
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case uint32:
+			buf = binary.NativeEndian.AppendUint32(buf, v)
+		case string:
+			buf = binary.NativeEndian.AppendUint32(buf, uint32(len(v)+1))
+			buf = append(buf, v...)
+			buf = append(buf, make([]byte, 4-len(v)%4)...)
+		default:
+			return fmt.Errorf("wayland: unsupported argument type %T", arg)
+		}
+	}
+
+	binary.NativeEndian.PutUint32(buf, object)
+	binary.NativeEndian.PutUint32(buf[4:], uint32(len(buf))<<16|uint32(opcode))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.Write(buf)
+	return err
+}
+
+// read reads the next message.
+func (c *wlConn) read() (wlMessage, error) {
+	header := make([]byte, wlHeaderSize)
+	_, err := io.ReadFull(c.reader, header)
+	if err != nil {
+		return wlMessage{}, err
+	}
+
+	msg := wlMessage{sender: binary.NativeEndian.Uint32(header)}
+	v := binary.NativeEndian.Uint32(header[4:])
+	msg.opcode = uint16(v)
+
+	size := int(v >> 16)
+	if size < wlHeaderSize {
+		return wlMessage{}, fmt.Errorf("wayland: invalid message size %d", size)
+	}
+
+	msg.args = make([]byte, size-wlHeaderSize)
+	_, err = io.ReadFull(c.reader, msg.args)
+	if err != nil {
+		return wlMessage{}, err
+	}
+
+	return msg, nil
+}
+
+// wlGlobal is a global object announced by the compositor.
+type wlGlobal struct {
+	name    uint32
+	version uint32
+}
+
+// globals returns the globals announced by the compositor, keyed by
+// interface name. It must be called before other messages are read from
+// the connection.
+func (c *wlConn) globals() (registry uint32, globals map[string]wlGlobal, err error) {
+	registry = c.newID()
+	err = c.send(wlDisplayID, wlDisplayGetRegistry, registry)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// the callback is done once all globals have been sent.
+	callback := c.newID()
+	err = c.send(wlDisplayID, wlDisplaySync, callback)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	globals = make(map[string]wlGlobal)
+	for {
+		msg, err := c.read()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch {
+		case msg.sender == callback && msg.opcode == wlCallbackDone:
+			return registry, globals, nil
+		case msg.sender == wlDisplayID && msg.opcode == wlDisplayError:
+			return 0, nil, wlError(msg)
+		case msg.sender == registry && msg.opcode == wlRegistryGlobal:
+			name, _ := msg.uint32()
+			iface, err := msg.string()
+			if err != nil {
+				return 0, nil, err
+			}
+			version, _ := msg.uint32()
+			globals[iface] = wlGlobal{name: name, version: version}
+		}
+	}
+}
+
+// bind binds the global to a new object.
+func (c *wlConn) bind(registry uint32, iface string, global wlGlobal, version uint32) (uint32, error) {
+	if global.version < version {
+		version = global.version
+	}
+
+	id := c.newID()
+	err := c.send(registry, wlRegistryBind, global.name, iface, version, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// wlError decodes a wl_display error event.
+func wlError(msg wlMessage) error {
+	object, _ := msg.uint32()
+	code, _ := msg.uint32()
+	message, _ := msg.string()
+	return fmt.Errorf("wayland: error on object %d (%d): %s", object, code, message)
+}
@@ -0,0 +1,89 @@
+//go:build cgo && !nox11
+
+package lis
+
+// #cgo pkg-config: x11 xext
+// #include <X11/Xlib.h>
+// #include <X11/extensions/dpms.h>
+//
+// /* force the DPMS level of the display. Returns 0 on success, -1 if the
+//  * display can't be opened and -2 if it doesn't support DPMS. */
+// static int lis_dpms_set(int on) {
+// 	Display *display;
+// 	int event_base, error_base;
+//
+// 	display = XOpenDisplay(NULL);
+// 	if (display == NULL) {
+// 		return -1;
+// 	}
+//
+// 	if (!DPMSQueryExtension(display, &event_base, &error_base) || !DPMSCapable(display)) {
+// 		XCloseDisplay(display);
+// 		return -2;
+// 	}
+//
+// 	/* DPMS must be enabled for the level to be forced. */
+// 	DPMSEnable(display);
+// 	DPMSForceLevel(display, on ? DPMSModeOn : DPMSModeOff);
+// 	XCloseDisplay(display);
+// 	return 0;
+// }
+//
+// /* get the DPMS level of the display. Returns the level, -1 if the display
+//  * can't be opened and -2 if it doesn't support DPMS. */
+// static int lis_dpms_get(void) {
+// 	Display *display;
+// 	CARD16 level;
+// 	BOOL enabled;
+// 	int event_base, error_base;
+//
+// 	display = XOpenDisplay(NULL);
+// 	if (display == NULL) {
+// 		return -1;
+// 	}
+//
+// 	if (!DPMSQueryExtension(display, &event_base, &error_base) ||
+// 	    !DPMSInfo(display, &level, &enabled)) {
+// 		XCloseDisplay(display);
+// 		return -2;
+// 	}
+//
+// 	XCloseDisplay(display);
+// 	return level;
+// }
+import "C"
+
+import "fmt"
+
+func init() {
+	x11DPMS = xSetDPMS
+}
+
+// xSetDPMS forces the displays of the X server on or off.
+func xSetDPMS(on bool) error {
+	var v C.int
+	if on {
+		v = 1
+	}
+
+	switch C.lis_dpms_set(v) {
+	case 0:
+		return nil
+	case -1:
+		return fmt.Errorf("dpms: unable to open X display")
+	default:
+		return fmt.Errorf("dpms: X server doesn't support DPMS")
+	}
+}
+
+// xDPMSOn returns true if the displays of the X server are on.
+func xDPMSOn() (bool, error) {
+	switch level := C.lis_dpms_get(); level {
+	case -1:
+		return false, fmt.Errorf("dpms: unable to open X display")
+	case -2:
+		return false, fmt.Errorf("dpms: X server doesn't support DPMS")
+	default:
+		return level == C.DPMSModeOn, nil
+	}
+}
@@ -0,0 +1,29 @@
+//go:build cgo && !nox11
+
+package lis
+
+import "testing"
+
+func TestX11DPMS(t *testing.T) {
+	startXvfb(t)
+
+	if _, err := xDPMSOn(); err != nil {
+		t.Skipf("DPMS not available: %s", err)
+	}
+
+	for _, on := range []bool{false, true} {
+		err := xSetDPMS(on)
+		if err != nil {
+			t.Fatalf("failed to set DPMS: %s", err)
+		}
+
+		state, err := xDPMSOn()
+		if err != nil {
+			t.Fatalf("failed to get DPMS state: %s", err)
+		}
+
+		if state != on {
+			t.Errorf("expected DPMS on=%t, got %t", on, state)
+		}
+	}
+}
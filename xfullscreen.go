@@ -0,0 +1,160 @@
+//go:build cgo && !nox11
+
+package lis
+
+// #cgo pkg-config: x11
+// #include <stdlib.h>
+// #include <string.h>
+// #include <X11/Xatom.h>
+// #include <X11/Xlib.h>
+// #include <X11/Xutil.h>
+//
+// /* windows may be destroyed while being queried, ignore the errors of the
+//  * query display rather than exiting through the default error handler.
+//  * Errors of other displays are passed to the previous handler. */
+// static Display *lis_query_display;
+// static XErrorHandler lis_prev_handler;
+//
+// static int lis_ignore_error(Display *display, XErrorEvent *ev) {
+// 	if (display != lis_query_display && lis_prev_handler != NULL) {
+// 		return lis_prev_handler(display, ev);
+// 	}
+// 	return 0;
+// }
+//
+// /* returns the first 32 bit value of the window property, or 0 if not
+//  * set. The type is not checked, some tools set windows as CARDINAL. */
+// static unsigned long lis_window_prop(Display *display, Window w, Atom prop) {
+// 	Atom actual_type;
+// 	int format;
+// 	unsigned long n, remaining, value = 0;
+// 	unsigned char *data = NULL;
+//
+// 	if (XGetWindowProperty(display, w, prop, 0, 1, False, AnyPropertyType, &actual_type,
+// 	    &format, &n, &remaining, &data) == Success && data != NULL) {
+// 		if (format == 32 && n > 0) {
+// 			value = ((unsigned long *)data)[0];
+// 		}
+// 		XFree(data);
+// 	}
+//
+// 	return value;
+// }
+//
+// /* returns 1 if the window has the fullscreen state. */
+// static int lis_window_fullscreen(Display *display, Window w) {
+// 	Atom actual_type, fullscreen;
+// 	int format, found = 0;
+// 	unsigned long n, remaining, i;
+// 	unsigned char *data = NULL;
+//
+// 	fullscreen = XInternAtom(display, "_NET_WM_STATE_FULLSCREEN", False);
+// 	if (XGetWindowProperty(display, w, XInternAtom(display, "_NET_WM_STATE", False),
+// 	    0, 64, False, XA_ATOM, &actual_type, &format, &n, &remaining, &data) == Success &&
+// 	    data != NULL) {
+// 		if (actual_type == XA_ATOM && format == 32) {
+// 			for (i = 0; i < n; i++) {
+// 				if (((Atom *)data)[i] == fullscreen) {
+// 					found = 1;
+// 					break;
+// 				}
+// 			}
+// 		}
+// 		XFree(data);
+// 	}
+//
+// 	return found;
+// }
+//
+// /* checks if the active window is fullscreen. Returns 1 if it is, 0 if not
+//  * and -1 if the display can't be opened. The WM_CLASS of a fullscreen
+//  * window is stored in instance and class, which must be freed. */
+// static int lis_fullscreen(char **instance, char **class) {
+// 	Display *display;
+// 	Window active;
+// 	XClassHint hint;
+// 	int fullscreen = 0;
+//
+// 	*instance = NULL;
+// 	*class = NULL;
+//
+// 	display = XOpenDisplay(NULL);
+// 	if (display == NULL) {
+// 		return -1;
+// 	}
+// 	lis_query_display = display;
+// 	lis_prev_handler = XSetErrorHandler(lis_ignore_error);
+//
+// 	active = lis_window_prop(display, DefaultRootWindow(display),
+// 		XInternAtom(display, "_NET_ACTIVE_WINDOW", False));
+// 	if (active != None && lis_window_fullscreen(display, active)) {
+// 		fullscreen = 1;
+// 		if (XGetClassHint(display, active, &hint)) {
+// 			if (hint.res_name != NULL) {
+// 				*instance = strdup(hint.res_name);
+// 				XFree(hint.res_name);
+// 			}
+// 			if (hint.res_class != NULL) {
+// 				*class = strdup(hint.res_class);
+// 				XFree(hint.res_class);
+// 			}
+// 		}
+// 	}
+//
+// 	/* handle the errors of the query before restoring the handler. */
+// 	XSync(display, False);
+// 	XSetErrorHandler(lis_prev_handler);
+// 	lis_query_display = NULL;
+//
+// 	XCloseDisplay(display);
+// 	return fullscreen;
+// }
+import "C"
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// xFullscreenMu serializes the checks, as they share the C statics of the
+// error handler and replace the process-wide Xlib error handler.
+var xFullscreenMu sync.Mutex
+
+func init() {
+	x11FullscreenWindow = xFullscreenWindow
+}
+
+// xFullscreenWindow returns the WM_CLASS of the active window given by
+// _NET_ACTIVE_WINDOW if it has the _NET_WM_STATE_FULLSCREEN state. A
+// connection is opened for each check, as the connection of the x11 idle
+// source is busy waiting for alarms in another thread. The error handler
+// is only replaced for the duration of the check, errors of other displays
+// are passed on to the previous handler. Concurrent checks are serialized,
+// other code must not replace the error handler while a check runs.
+func xFullscreenWindow() (wmClass, bool, error) {
+	xFullscreenMu.Lock()
+	defer xFullscreenMu.Unlock()
+
+	var instance, class *C.char
+	ret := C.lis_fullscreen(&instance, &class)
+	defer C.free(unsafe.Pointer(instance))
+	defer C.free(unsafe.Pointer(class))
+
+	switch ret {
+	case -1:
+		return wmClass{}, false, fmt.Errorf("xfullscreen: unable to open X display")
+	case 0:
+		return wmClass{}, false, nil
+	}
+
+	c := wmClass{}
+	if instance != nil {
+		c.instance = C.GoString(instance)
+	}
+	if class != nil {
+		c.class = C.GoString(class)
+	}
+
+	return c, true, nil
+}
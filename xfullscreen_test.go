@@ -0,0 +1,77 @@
+//go:build cgo && !nox11
+
+package lis
+
+import (
+	"os/exec"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// xprop runs xprop with args on the root window.
+func xprop(t *testing.T, args ...string) {
+	t.Helper()
+
+	out, err := exec.Command("xprop", append([]string{"-root"}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("xprop failed: %s: %s", err, out)
+	}
+}
+
+func TestX11Fullscreen(t *testing.T) {
+	startXvfb(t, "xprop", "xwininfo")
+
+	_, ok, err := xFullscreenWindow()
+	if err != nil {
+		t.Fatalf("failed to check for fullscreen window: %s", err)
+	}
+
+	if ok {
+		t.Fatalf("expected no fullscreen window without an active window")
+	}
+
+	// without a window manager the root window is used as the active
+	// window.
+	out, err := exec.Command("xwininfo", "-root").Output()
+	if err != nil {
+		t.Fatalf("xwininfo failed: %s", err)
+	}
+
+	root := regexp.MustCompile(`Window id: (0x[0-9a-f]+)`).FindStringSubmatch(string(out))
+	if root == nil {
+		t.Fatalf("failed to find root window id in: %s", out)
+	}
+
+	xprop(t, "-f", "_NET_ACTIVE_WINDOW", "32x", "-set", "_NET_ACTIVE_WINDOW", root[1])
+	xprop(t, "-f", "WM_CLASS", "8s", "-set", "WM_CLASS", "mpv")
+
+	_, ok, err = xFullscreenWindow()
+	if err != nil || ok {
+		t.Fatalf("expected active window not to be fullscreen, got %t, %v", ok, err)
+	}
+
+	xprop(t, "-f", "_NET_WM_STATE", "32a", "-set", "_NET_WM_STATE", "_NET_WM_STATE_FULLSCREEN")
+
+	class, ok, err := xFullscreenWindow()
+	if err != nil {
+		t.Fatalf("failed to check for fullscreen window: %s", err)
+	}
+
+	if !ok || class.instance != "mpv" {
+		t.Errorf("expected fullscreen window mpv, got %t, %+v", ok, class)
+	}
+
+	// concurrent checks don't interfere through the error handler.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, err := xFullscreenWindow(); err != nil || !ok {
+				t.Errorf("expected fullscreen window, got %t, %v", ok, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
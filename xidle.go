@@ -1,16 +1,183 @@
+//go:build cgo && !nox11
+
 package lis
 
-// #cgo pkg-config: x11 xscrnsaver
+// #cgo pkg-config: x11 xscrnsaver xext
+// #include <errno.h>
+// #include <poll.h>
+// #include <stdlib.h>
+// #include <string.h>
 // #include <X11/Xlib.h>
 // #include <X11/extensions/scrnsaver.h>
+// #include <X11/extensions/sync.h>
 //
 // /* wrapper around the DefaultRootWindow macro */
 // static Drawable wrap_DefaultRootWindow(Display *diplay) {
 // 	return DefaultRootWindow(diplay);
 // }
+//
+// typedef struct {
+// 	Display *display;
+// 	XSyncCounter counter;
+// 	XSyncAlarm alarm;
+// 	int event_base;
+// 	int positive;
+// 	long long wait_value;
+// } lis_xsync;
+//
+// /* open the display and look up the IDLETIME system counter. */
+// static int lis_xsync_open(lis_xsync *x) {
+// 	int error_base, major, minor, n, i;
+// 	XSyncSystemCounter *counters;
+//
+// 	x->display = XOpenDisplay(NULL);
+// 	if (x->display == NULL) {
+// 		return -1;
+// 	}
+//
+// 	x->counter = None;
+// 	x->alarm = None;
+// 	if (XSyncQueryExtension(x->display, &x->event_base, &error_base) &&
+// 	    XSyncInitialize(x->display, &major, &minor)) {
+// 		counters = XSyncListSystemCounters(x->display, &n);
+// 		for (i = 0; i < n; i++) {
+// 			if (strcmp(counters[i].name, "IDLETIME") == 0) {
+// 				x->counter = counters[i].counter;
+// 				break;
+// 			}
+// 		}
+// 		XSyncFreeSystemCounterList(counters);
+// 	}
+//
+// 	if (x->counter == None) {
+// 		XCloseDisplay(x->display);
+// 		x->display = NULL;
+// 		return -2;
+// 	}
+//
+// 	return 0;
+// }
+//
+// static void lis_xsync_close(lis_xsync *x) {
+// 	if (x->display == NULL) {
+// 		return;
+// 	}
+//
+// 	if (x->alarm != None) {
+// 		XSyncDestroyAlarm(x->display, x->alarm);
+// 	}
+// 	XCloseDisplay(x->display);
+// 	x->display = NULL;
+// 	x->alarm = None;
+// }
+//
+// /* returns the value of the IDLETIME counter in milliseconds. */
+// static long long lis_xsync_idle(lis_xsync *x) {
+// 	XSyncValue value;
+//
+// 	if (!XSyncQueryCounter(x->display, x->counter, &value)) {
+// 		return -1;
+// 	}
+//
+// 	return ((long long)XSyncValueHigh32(value) << 32) | (unsigned int)XSyncValueLow32(value);
+// }
+//
+// /* set the alarm to trigger when the idle time is >= ms if positive is
+//  * set, otherwise when the idle time is <= ms. The alarm triggers right
+//  * away if the condition is already true. */
+// static void lis_xsync_set_alarm(lis_xsync *x, long long ms, int positive) {
+// 	XSyncAlarmAttributes attr;
+// 	unsigned long flags = XSyncCACounter | XSyncCAValueType | XSyncCATestType |
+// 		XSyncCAValue | XSyncCADelta;
+//
+// 	attr.trigger.counter = x->counter;
+// 	attr.trigger.value_type = XSyncAbsolute;
+// 	attr.trigger.test_type = positive ? XSyncPositiveComparison : XSyncNegativeComparison;
+// 	XSyncIntsToValue(&attr.trigger.wait_value, (unsigned int)(ms & 0xffffffff), (int)(ms >> 32));
+// 	XSyncIntToValue(&attr.delta, 0);
+//
+// 	x->positive = positive;
+// 	x->wait_value = ms;
+//
+// 	if (x->alarm == None) {
+// 		x->alarm = XSyncCreateAlarm(x->display, flags, &attr);
+// 	} else {
+// 		XSyncChangeAlarm(x->display, x->alarm, flags, &attr);
+// 	}
+// }
+//
+// /* returns 1 if the alarm event matches the current alarm condition. Events
+//  * of a previous alarm condition may still be queued. */
+// static int lis_xsync_match(lis_xsync *x, XSyncAlarmNotifyEvent *ev) {
+// 	long long value;
+//
+// 	if (ev->alarm != x->alarm) {
+// 		return 0;
+// 	}
+//
+// 	value = ((long long)XSyncValueHigh32(ev->counter_value) << 32) |
+// 		(unsigned int)XSyncValueLow32(ev->counter_value);
+// 	return x->positive ? value >= x->wait_value : value <= x->wait_value;
+// }
+//
+// /* wait for the alarm to trigger. Returns 1 when triggered, 0 when woken
+//  * up through wakefd and -1 if the connection failed. */
+// static int lis_xsync_wait(lis_xsync *x, int wakefd) {
+// 	XEvent ev;
+// 	struct pollfd fds[2];
+//
+// 	XFlush(x->display);
+// 	for (;;) {
+// 		while (XPending(x->display) > 0) {
+// 			XNextEvent(x->display, &ev);
+// 			if (ev.type == x->event_base + XSyncAlarmNotify &&
+// 			    lis_xsync_match(x, (XSyncAlarmNotifyEvent *)&ev)) {
+// 				return 1;
+// 			}
+// 		}
+//
+// 		fds[0].fd = ConnectionNumber(x->display);
+// 		fds[0].events = POLLIN;
+// 		fds[1].fd = wakefd;
+// 		fds[1].events = POLLIN;
+// 		if (poll(fds, 2, -1) < 0) {
+// 			if (errno == EINTR) {
+// 				continue;
+// 			}
+// 			return -1;
+// 		}
+//
+// 		if (fds[1].revents & POLLIN) {
+// 			return 0;
+// 		}
+//
+// 		if (fds[0].revents & (POLLERR | POLLHUP)) {
+// 			return -1;
+// 		}
+// 	}
+// }
 import "C"
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var errXSyncUnsupported = errors.New("xidle: XSync IDLETIME counter not available")
+
+func init() {
+	// Xlib is used from several threads: the connection of the idle
+	// source waits for alarms while DPMS and fullscreen windows are
+	// queried on connections of their own.
+	C.XInitThreads()
+	idleSources[IdleSourceX11] = newX11Idle
+}
 
 // XIdle returns the xserver idle time in miliseconds.
 func XIdle() (uint, error) {
@@ -30,3 +197,166 @@ func XIdle() (uint, error) {
 
 	return 0, fmt.Errorf("XScreenSaver Extension not present")
 }
+
+// x11Idle detects idle and activity through alarms on the XSync IDLETIME
+// system counter using a single persistent X connection. If the X server
+// doesn't provide the counter, the screensaver extension is polled
+// instead.
+type x11Idle struct {
+	mu     sync.Mutex
+	x      *C.lis_xsync
+	wake   [2]int // pipe used to interrupt waiting for an alarm
+	noSync bool   // true if the IDLETIME counter is not available
+}
+
+func newX11Idle(IdleSourceOptions) (IdleSource, error) {
+	s := &x11Idle{
+		x: (*C.lis_xsync)(C.calloc(1, C.sizeof_lis_xsync)),
+	}
+
+	err := syscall.Pipe2(s.wake[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC)
+	if err != nil {
+		C.free(unsafe.Pointer(s.x))
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// connect opens the X connection if not already open. The connection is
+// opened lazily such that lis can be started before the X server.
+func (s *x11Idle) connect() error {
+	if s.noSync {
+		return errXSyncUnsupported
+	}
+
+	if s.x.display != nil {
+		return nil
+	}
+
+	switch C.lis_xsync_open(s.x) {
+	case 0:
+		return nil
+	case -1:
+		return fmt.Errorf("xidle: unable to open X display")
+	default:
+		slog.Info("XSync IDLETIME counter not available, polling XScreenSaver instead")
+		s.noSync = true
+		return errXSyncUnsupported
+	}
+}
+
+// wait waits for the alarm to trigger or ctx to be cancelled.
+func (s *x11Idle) wait(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		syscall.Write(s.wake[1], []byte{0})
+	})
+	defer stop()
+
+	for {
+		s.drain()
+
+		switch C.lis_xsync_wait(s.x, C.int(s.wake[0])) {
+		case 1:
+			return nil
+		case 0:
+			// the wake up may be left over from a previous wait.
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+		default:
+			C.lis_xsync_close(s.x)
+			return fmt.Errorf("xidle: lost connection to X display")
+		}
+	}
+}
+
+// drain empties the wake up pipe.
+func (s *x11Idle) drain() {
+	buf := make([]byte, 16)
+	for {
+		n, err := syscall.Read(s.wake[0], buf)
+		if n <= 0 || err != nil {
+			return
+		}
+	}
+}
+
+// WaitIdle waits for the IDLETIME counter to reach timeout.
+func (s *x11Idle) WaitIdle(ctx context.Context, timeout time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.connect()
+	if err == errXSyncUnsupported {
+		return xssWaitIdle(ctx, timeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	C.lis_xsync_set_alarm(s.x, C.longlong(timeout.Milliseconds()), 1)
+	return s.wait(ctx)
+}
+
+// WaitActive waits for the IDLETIME counter to be reset by user activity.
+func (s *x11Idle) WaitActive(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.connect()
+	if err == errXSyncUnsupported {
+		return ErrActivityUnsupported
+	}
+	if err != nil {
+		return err
+	}
+
+	idle := C.lis_xsync_idle(s.x)
+	if idle < 0 {
+		return fmt.Errorf("xidle: failed to query IDLETIME counter")
+	}
+
+	// the user was active in the last millisecond.
+	if idle < 1 {
+		return nil
+	}
+
+	C.lis_xsync_set_alarm(s.x, idle-1, 0)
+	return s.wait(ctx)
+}
+
+// Close closes the X connection.
+func (s *x11Idle) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	C.lis_xsync_close(s.x)
+	C.free(unsafe.Pointer(s.x))
+	s.x = nil
+	syscall.Close(s.wake[0])
+	syscall.Close(s.wake[1])
+	return nil
+}
+
+// xssWaitIdle polls the X idle time from the screensaver extension until
+// it exceeds timeout.
+func xssWaitIdle(ctx context.Context, timeout time.Duration) error {
+	for {
+		idleTime, err := XIdle()
+		if err != nil {
+			return err
+		}
+
+		idle := time.Duration(idleTime) * time.Millisecond
+		if idle >= timeout {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(timeout - idle):
+		}
+	}
+}
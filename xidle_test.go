@@ -0,0 +1,103 @@
+//go:build cgo && !nox11
+
+package lis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// startXvfb starts an Xvfb server and points DISPLAY at it. The test is
+// skipped if Xvfb or any of the tools used by the test is not installed.
+func startXvfb(t *testing.T, tools ...string) {
+	t.Helper()
+
+	for _, bin := range append([]string{"Xvfb"}, tools...) {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not available", bin)
+		}
+	}
+
+	display := fmt.Sprintf(":%d", 90+os.Getpid()%100)
+	cmd := exec.Command("Xvfb", display, "-nolisten", "tcp")
+	err := cmd.Start()
+	if err != nil {
+		t.Fatalf("failed to start Xvfb: %s", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	t.Setenv("DISPLAY", display)
+
+	// wait for the server to accept connections.
+	for i := 0; i < 50; i++ {
+		if _, err := XIdle(); err == nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Xvfb did not start on display %s", display)
+}
+
+func TestX11Idle(t *testing.T) {
+	startXvfb(t, "xdotool")
+
+	source, err := newX11Idle(IdleSourceOptions{})
+	if err != nil {
+		t.Fatalf("failed to create x11 idle source: %s", err)
+	}
+	defer source.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err = source.WaitIdle(ctx, 500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitIdle failed: %s", err)
+	}
+
+	active := make(chan error, 1)
+	go func() {
+		active <- source.WaitActive(ctx)
+	}()
+
+	// WaitActive must block until there is input.
+	select {
+	case err := <-active:
+		t.Fatalf("WaitActive returned without input: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	err = exec.Command("xdotool", "mousemove", "10", "10").Run()
+	if err != nil {
+		t.Fatalf("xdotool failed: %s", err)
+	}
+
+	select {
+	case err := <-active:
+		if err != nil {
+			t.Fatalf("WaitActive failed: %s", err)
+		}
+	case <-ctx.Done():
+		t.Fatalf("WaitActive did not detect input after %s", time.Since(start))
+	}
+
+	// waiting is interrupted when the context is cancelled.
+	cctx, ccancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ccancel()
+	}()
+
+	err = source.WaitIdle(cctx, time.Hour)
+	if err != context.Canceled {
+		t.Fatalf("expected WaitIdle to be cancelled, got: %v", err)
+	}
+}